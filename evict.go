@@ -0,0 +1,101 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package lru
+
+import "sync"
+
+// evictQueue runs an eviction callback on dedicated worker goroutines. With
+// a single worker the entries are passed one at a time and in the order in
+// which they were queued.
+type evictQueue[K comparable, V any] struct {
+	onEvict func(key K, value V)
+	lock    sync.Mutex
+	cond    *sync.Cond // signalled when an entry is queued
+	space   *sync.Cond // signalled when an entry is taken off a full queue
+	keys    []K
+	vals    []V
+	fns     []func(key K, value V)
+	limit   int
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// newEvictQueue starts workers goroutines calling onEvict. If limit is
+// positive, push blocks while limit entries are pending.
+func newEvictQueue[K comparable, V any](onEvict func(key K, value V), workers, limit int) *evictQueue[K, V] {
+	q := &evictQueue[K, V]{
+		onEvict: onEvict,
+		limit:   limit,
+	}
+	q.cond = sync.NewCond(&q.lock)
+	q.space = sync.NewCond(&q.lock)
+	q.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.run()
+	}
+	return q
+}
+
+// setOnEvict replaces the callback for the entries pushed later, while the
+// queued ones keep the callback which was current when they were pushed.
+// If onEvict is nil, entries are dropped.
+func (q *evictQueue[K, V]) setOnEvict(onEvict func(key K, value V)) {
+	q.lock.Lock()
+	q.onEvict = onEvict
+	q.lock.Unlock()
+}
+
+// push queues an evicted entry, waiting for space if the queue is full.
+// Entries pushed after close or without a callback are dropped.
+func (q *evictQueue[K, V]) push(k K, v V) {
+	q.lock.Lock()
+	for q.limit > 0 && len(q.keys) >= q.limit && !q.closed {
+		q.space.Wait()
+	}
+	if !q.closed && q.onEvict != nil {
+		q.keys = append(q.keys, k)
+		q.vals = append(q.vals, v)
+		q.fns = append(q.fns, q.onEvict)
+		q.cond.Signal()
+	}
+	q.lock.Unlock()
+}
+
+// run calls the callback for queued entries until the queue is closed
+// and empty.
+func (q *evictQueue[K, V]) run() {
+	defer q.wg.Done()
+	var zeroK K
+	var zeroV V
+	q.lock.Lock()
+	for {
+		for len(q.keys) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.keys) == 0 {
+			q.lock.Unlock()
+			return
+		}
+		k, v, onEvict := q.keys[0], q.vals[0], q.fns[0]
+		q.keys[0], q.vals[0], q.fns[0] = zeroK, zeroV, nil
+		q.keys, q.vals, q.fns = q.keys[1:], q.vals[1:], q.fns[1:]
+		if len(q.keys) == 0 {
+			q.keys, q.vals, q.fns = nil, nil, nil
+		}
+		q.space.Signal()
+		q.lock.Unlock()
+		onEvict(k, v)
+		q.lock.Lock()
+	}
+}
+
+// close waits for the pending callbacks to finish and stops the workers.
+func (q *evictQueue[K, V]) close() {
+	q.lock.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.space.Broadcast()
+	q.lock.Unlock()
+	q.wg.Wait()
+}
@@ -0,0 +1,37 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package lru
+
+import "time"
+
+// HTTPCache adapts a Cache to the storage interface of httpcache-style
+// middleware, which stores serialized responses under string keys.
+type HTTPCache struct {
+	cache *Cache[string, []byte]
+}
+
+// NewHTTPCache creates an HTTPCache holding up to size responses, each for
+// at most ttl if it is positive.
+func NewHTTPCache(size int, ttl time.Duration, opts ...Option[string, []byte]) (*HTTPCache, error) {
+	c, err := NewWithEvictTTL[string, []byte](size, nil, ttl, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &HTTPCache{cache: c}, nil
+}
+
+// Get returns the response stored for key, if any.
+func (h *HTTPCache) Get(key string) (responseBytes []byte, ok bool) {
+	return h.cache.Get(key)
+}
+
+// Set stores the response for key, replacing any previous one.
+func (h *HTTPCache) Set(key string, responseBytes []byte) {
+	h.cache.Add(key, responseBytes)
+}
+
+// Delete removes the response stored for key.
+func (h *HTTPCache) Delete(key string) {
+	h.cache.Remove(key)
+}
@@ -0,0 +1,51 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package lru
+
+import (
+	"testing"
+	"time"
+
+	"github.com/craumix/golang-lru/simplelru"
+)
+
+func TestHTTPCache(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+	h, err := NewHTTPCache(2, time.Minute,
+		WithLRUOptions(simplelru.WithClock[string, []byte](clock)))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, ok := h.Get("/a"); ok {
+		t.Errorf("empty cache should miss")
+	}
+	h.Set("/a", []byte("a1"))
+	h.Set("/a", []byte("a2"))
+	h.Set("/b", []byte("b"))
+	if resp, ok := h.Get("/a"); !ok || string(resp) != "a2" {
+		t.Errorf("bad response for /a: %q, %v", resp, ok)
+	}
+
+	h.Set("/c", []byte("c"))
+	if _, ok := h.Get("/b"); ok {
+		t.Errorf("/b should have been evicted")
+	}
+
+	h.Delete("/a")
+	h.Delete("/missing")
+	if _, ok := h.Get("/a"); ok {
+		t.Errorf("/a should have been deleted")
+	}
+
+	now = now.Add(time.Minute * 2)
+	if _, ok := h.Get("/c"); ok {
+		t.Errorf("/c should have expired")
+	}
+
+	if _, err := NewHTTPCache(0, time.Minute); err == nil {
+		t.Errorf("should fail without a size")
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package lru
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// LockStats describes the time operations on a Cache waited for its lock.
+type LockStats struct {
+	// Acquisitions is the number of times the lock was acquired.
+	Acquisitions uint64
+	// Contended is the number of acquisitions which had to wait, because
+	// the lock was held by another operation.
+	Contended uint64
+	// TotalWait is the time all acquisitions waited for the lock.
+	TotalWait time.Duration
+	// MaxWait is the longest time an acquisition waited for the lock.
+	MaxWait time.Duration
+}
+
+// MeanWait returns the average time an acquisition waited for the lock.
+func (s LockStats) MeanWait() time.Duration {
+	if s.Acquisitions == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(s.Acquisitions)
+}
+
+// lockStats collects LockStats. Readers acquire the lock concurrently, so
+// the counters are updated atomically.
+type lockStats struct {
+	acquisitions atomic.Uint64
+	contended    atomic.Uint64
+	totalWait    atomic.Int64
+	maxWait      atomic.Int64
+}
+
+func (s *lockStats) record(wait time.Duration) {
+	s.acquisitions.Add(1)
+	if wait == 0 {
+		return
+	}
+	s.contended.Add(1)
+	s.totalWait.Add(int64(wait))
+	for {
+		prev := s.maxWait.Load()
+		if int64(wait) <= prev || s.maxWait.CompareAndSwap(prev, int64(wait)) {
+			return
+		}
+	}
+}
+
+// acquire locks the cache for writing, measuring the wait if enabled by
+// WithLockStats. Acquisitions which do not wait do not read the clock.
+func (c *Cache[K, V]) acquire() {
+	if c.lockStats == nil {
+		c.lock.Lock()
+		return
+	}
+	if c.lock.TryLock() {
+		c.lockStats.record(0)
+		return
+	}
+	start := time.Now()
+	c.lock.Lock()
+	c.lockStats.record(time.Since(start))
+}
+
+// acquireRead is like acquire for reading.
+func (c *Cache[K, V]) acquireRead() {
+	if c.lockStats == nil {
+		c.lock.RLock()
+		return
+	}
+	if c.lock.TryRLock() {
+		c.lockStats.record(0)
+		return
+	}
+	start := time.Now()
+	c.lock.RLock()
+	c.lockStats.record(time.Since(start))
+}
+
+// LockStats returns the time operations waited for the lock of the cache,
+// which is only measured if enabled by WithLockStats.
+func (c *Cache[K, V]) LockStats() LockStats {
+	s := c.lockStats
+	if s == nil {
+		return LockStats{}
+	}
+	return LockStats{
+		Acquisitions: s.acquisitions.Load(),
+		Contended:    s.contended.Load(),
+		TotalWait:    time.Duration(s.totalWait.Load()),
+		MaxWait:      time.Duration(s.maxWait.Load()),
+	}
+}
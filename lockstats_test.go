@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package lru
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRULockStats(t *testing.T) {
+	l, err := NewWithEvictTTL[int, int](8, nil, 0, WithLockStats[int, int]())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+		l.Get(i)
+	}
+	l.Len()
+	stats := l.LockStats()
+	if stats != (LockStats{Acquisitions: 9}) || stats.MeanWait() != 0 {
+		t.Errorf("uncontended operations should not wait: %+v", stats)
+	}
+
+	// Add waits while the lock is held
+	l.lock.RLock()
+	done := make(chan struct{})
+	go func() {
+		l.Add(10, 10)
+		close(done)
+	}()
+	time.Sleep(time.Millisecond * 20)
+	l.lock.RUnlock()
+	<-done
+
+	stats = l.LockStats()
+	if stats.Acquisitions != 10 || stats.Contended != 1 {
+		t.Errorf("bad lock stats: %+v", stats)
+	}
+	if stats.MaxWait < time.Millisecond*10 || stats.TotalWait != stats.MaxWait {
+		t.Errorf("Add should have waited for the lock: %+v", stats)
+	}
+	if stats.MeanWait() != stats.TotalWait/10 {
+		t.Errorf("bad mean wait: %v", stats.MeanWait())
+	}
+
+	// Lock waits are not measured by default
+	l, err = New[int, int](8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	if stats := l.LockStats(); stats != (LockStats{}) {
+		t.Errorf("should have no lock stats: %+v", stats)
+	}
+}
@@ -4,6 +4,8 @@
 package lru
 
 import (
+	"bytes"
+	"io"
 	"sync"
 	"time"
 
@@ -17,11 +19,20 @@ const (
 
 // Cache is a thread-safe fixed size LRU cache.
 type Cache[K comparable, V any] struct {
-	lru         *simplelru.LRU[K, V]
-	evictedKeys []K
-	evictedVals []V
-	onEvictedCB func(k K, v V)
-	lock        sync.RWMutex
+	lru          *simplelru.LRU[K, V]
+	evictedKeys  []K
+	evictedVals  []V
+	onEvictedCB  func(k K, v V)
+	asyncEvict   bool
+	evictQueue   *evictQueue[K, V]
+	evictWorkers int
+	evictLimit   int
+	lruOpts      []simplelru.Option[K, V]
+	onClose      []func(entries []simplelru.Entry[K, V])
+	closeOnce    sync.Once
+	subs         []*subscriber[K, V]
+	lockStats    *lockStats
+	lock         sync.RWMutex
 }
 
 // New creates an LRU of the given size.
@@ -35,21 +46,42 @@ func NewWithEvict[K comparable, V any](size int, onEvicted func(key K, value V))
 	return NewWithEvictTTL[K, V](size, onEvicted, 0)
 }
 
-// NewWithEvict constructs a fixed size cache with the given eviction
-// callback, ttl for items and the option to enable expiry based eviction.
-func NewWithEvictTTL[K comparable, V any](size int, onEvicted func(key K, value V), itemTTL time.Duration) (c *Cache[K, V], err error) {
+// NewWithEvictTTL constructs a fixed size cache with the given eviction
+// callback, ttl for items and options.
+func NewWithEvictTTL[K comparable, V any](size int, onEvicted func(key K, value V), itemTTL time.Duration, opts ...Option[K, V]) (c *Cache[K, V], err error) {
 	// create a cache with default settings
 	c = &Cache[K, V]{
 		onEvictedCB: onEvicted,
 	}
-	if onEvicted != nil {
+	for _, opt := range opts {
+		if err = opt(c); err != nil {
+			return nil, err
+		}
+	}
+	if onEvicted != nil && c.asyncEvict {
+		// entries are queued while the lock is held to keep their order,
+		// so the buffering below is not needed
+		c.startEvictQueue(onEvicted)
+		c.onEvictedCB = nil
+		onEvicted = c.evictQueue.push
+	} else if onEvicted != nil {
 		c.initEvictBuffers()
 		onEvicted = c.onEvicted
 	}
-	c.lru, err = simplelru.NewLRUWithEvictTTL(size, onEvicted, itemTTL)
+	c.lru, err = simplelru.NewLRUWithEvictTTL(size, onEvicted, itemTTL, c.lruOpts...)
+	c.lruOpts = nil
 	return
 }
 
+// startEvictQueue starts the workers running onEvicted for WithAsyncEvict
+// and WithEvictWorkers.
+func (c *Cache[K, V]) startEvictQueue(onEvicted func(k K, v V)) {
+	if c.evictWorkers == 0 {
+		c.evictWorkers = 1
+	}
+	c.evictQueue = newEvictQueue(onEvicted, c.evictWorkers, c.evictLimit)
+}
+
 func (c *Cache[K, V]) initEvictBuffers() {
 	c.evictedKeys = make([]K, 0, DefaultEvictedBufferSize)
 	c.evictedVals = make([]V, 0, DefaultEvictedBufferSize)
@@ -62,21 +94,66 @@ func (c *Cache[K, V]) onEvicted(k K, v V) {
 	c.evictedVals = append(c.evictedVals, v)
 }
 
+// SetOnEvict replaces the eviction callback, or removes it if onEvicted is
+// nil, keeping the entries of the cache. Entries removed before are passed
+// to the previous callback, even if it runs later, as with WithAsyncEvict,
+// and the new callback is used for all entries removed afterwards.
+func (c *Cache[K, V]) SetOnEvict(onEvicted func(key K, value V)) {
+	c.acquire()
+	defer c.lock.Unlock()
+	switch {
+	case !c.asyncEvict:
+		c.onEvictedCB = onEvicted
+		if onEvicted != nil && c.evictedKeys == nil {
+			c.initEvictBuffers()
+		}
+	case c.evictQueue != nil:
+		c.evictQueue.setOnEvict(onEvicted)
+	case onEvicted != nil:
+		c.startEvictQueue(onEvicted)
+	}
+	c.lru.SetOnEvict(c.evictCallback())
+}
+
+// takeEvicted returns the entries buffered for the eviction callback and
+// resets the buffers, so that the callback can be called with them after
+// the lock has been released. It must be called with the lock held.
+func (c *Cache[K, V]) takeEvicted() (ks []K, vs []V) {
+	if len(c.evictedKeys) == 0 {
+		return nil, nil
+	}
+	ks, vs = c.evictedKeys, c.evictedVals
+	c.initEvictBuffers()
+	return ks, vs
+}
+
+// callEvicted calls onEvicted, if set, with the entries returned by
+// takeEvicted.
+func callEvicted[K comparable, V any](onEvicted func(k K, v V), ks []K, vs []V) {
+	if onEvicted == nil {
+		return
+	}
+	for i := range ks {
+		onEvicted(ks[i], vs[i])
+	}
+}
+
 // Purge is used to completely clear the cache.
 func (c *Cache[K, V]) Purge() {
 	var ks []K
 	var vs []V
-	c.lock.Lock()
+	c.acquire()
+	onEvicted := c.onEvictedCB
 	c.lru.Purge()
-	if c.onEvictedCB != nil && len(c.evictedKeys) > 0 {
+	if onEvicted != nil && len(c.evictedKeys) > 0 {
 		ks, vs = c.evictedKeys, c.evictedVals
 		c.initEvictBuffers()
 	}
 	c.lock.Unlock()
 	// invoke callback outside of critical section
-	if c.onEvictedCB != nil {
+	if onEvicted != nil {
 		for i := 0; i < len(ks); i++ {
-			c.onEvictedCB(ks[i], vs[i])
+			onEvicted(ks[i], vs[i])
 		}
 	}
 }
@@ -85,64 +162,121 @@ func (c *Cache[K, V]) Purge() {
 func (c *Cache[K, V]) Add(key K, value V) (evicted bool) {
 	var k K
 	var v V
-	c.lock.Lock()
+	c.acquire()
+	onEvicted := c.onEvictedCB
 	evicted = c.lru.Add(key, value)
-	if c.onEvictedCB != nil && evicted {
+	if onEvicted != nil && evicted {
 		k, v = c.evictedKeys[0], c.evictedVals[0]
 		c.evictedKeys, c.evictedVals = c.evictedKeys[:0], c.evictedVals[:0]
 	}
 	c.lock.Unlock()
-	if c.onEvictedCB != nil && evicted {
-		c.onEvictedCB(k, v)
+	if onEvicted != nil && evicted {
+		onEvicted(k, v)
 	}
 	return
 }
 
 // Get looks up a key's value from the cache.
 func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
-	c.lock.Lock()
+	c.acquire()
+	onEvicted := c.onEvictedCB
 	value, ok = c.lru.Get(key)
+	ks, vs := c.takeEvicted()
 	c.lock.Unlock()
+	callEvicted(onEvicted, ks, vs)
 	return value, ok
 }
 
+// GetOrReserve looks up a key's value from the cache, or reserves the key
+// on a miss if no other caller has, see simplelru.LRU.GetOrReserve. The
+// caller which gets reserved must call Fulfill or Abandon.
+func (c *Cache[K, V]) GetOrReserve(key K) (value V, hit, reserved bool) {
+	c.acquire()
+	onEvicted := c.onEvictedCB
+	value, hit, reserved = c.lru.GetOrReserve(key)
+	ks, vs := c.takeEvicted()
+	c.lock.Unlock()
+	callEvicted(onEvicted, ks, vs)
+	return value, hit, reserved
+}
+
+// Fulfill adds the value computed for a key reserved by GetOrReserve, and
+// releases the reservation. Returns true if an eviction occurred.
+func (c *Cache[K, V]) Fulfill(key K, value V) (evicted bool) {
+	c.acquire()
+	onEvicted := c.onEvictedCB
+	evicted = c.lru.Fulfill(key, value)
+	ks, vs := c.takeEvicted()
+	c.lock.Unlock()
+	callEvicted(onEvicted, ks, vs)
+	return evicted
+}
+
+// Abandon releases the reservation of a key by GetOrReserve
+// without adding a value.
+func (c *Cache[K, V]) Abandon(key K) {
+	c.acquire()
+	c.lru.Abandon(key)
+	c.lock.Unlock()
+}
+
 // Contains checks if a key is in the cache, without updating the
 // recent-ness or deleting it for being stale.
 func (c *Cache[K, V]) Contains(key K) bool {
-	c.lock.RLock()
+	// the write lock is needed to update the stats
+	// and remove the entry if it has expired
+	c.acquire()
+	onEvicted := c.onEvictedCB
 	containKey := c.lru.Contains(key)
-	c.lock.RUnlock()
+	ks, vs := c.takeEvicted()
+	c.lock.Unlock()
+	callEvicted(onEvicted, ks, vs)
 	return containKey
 }
 
 // Peek returns the key value (or undefined if not found) without updating
 // the "recently used"-ness of the key.
 func (c *Cache[K, V]) Peek(key K) (value V, ok bool) {
-	c.lock.RLock()
+	// the write lock is needed to update the stats
+	// and remove the entry if it has expired
+	c.acquire()
+	onEvicted := c.onEvictedCB
 	value, ok = c.lru.Peek(key)
-	c.lock.RUnlock()
+	ks, vs := c.takeEvicted()
+	c.lock.Unlock()
+	callEvicted(onEvicted, ks, vs)
 	return value, ok
 }
 
+// PeekMulti returns the values of the given keys which are in the cache and
+// have not expired, without updating their "recently used"-ness.
+func (c *Cache[K, V]) PeekMulti(keys []K) map[K]V {
+	c.acquireRead()
+	values := c.lru.PeekMulti(keys)
+	c.lock.RUnlock()
+	return values
+}
+
 // ContainsOrAdd checks if a key is in the cache without updating the
 // recent-ness or deleting it for being stale, and if not, adds the value.
 // Returns whether found and whether an eviction occurred.
 func (c *Cache[K, V]) ContainsOrAdd(key K, value V) (ok, evicted bool) {
 	var k K
 	var v V
-	c.lock.Lock()
+	c.acquire()
+	onEvicted := c.onEvictedCB
 	if c.lru.Contains(key) {
 		c.lock.Unlock()
 		return true, false
 	}
 	evicted = c.lru.Add(key, value)
-	if c.onEvictedCB != nil && evicted {
+	if onEvicted != nil && evicted {
 		k, v = c.evictedKeys[0], c.evictedVals[0]
 		c.evictedKeys, c.evictedVals = c.evictedKeys[:0], c.evictedVals[:0]
 	}
 	c.lock.Unlock()
-	if c.onEvictedCB != nil && evicted {
-		c.onEvictedCB(k, v)
+	if onEvicted != nil && evicted {
+		onEvicted(k, v)
 	}
 	return false, evicted
 }
@@ -153,20 +287,21 @@ func (c *Cache[K, V]) ContainsOrAdd(key K, value V) (ok, evicted bool) {
 func (c *Cache[K, V]) PeekOrAdd(key K, value V) (previous V, ok, evicted bool) {
 	var k K
 	var v V
-	c.lock.Lock()
+	c.acquire()
+	onEvicted := c.onEvictedCB
 	previous, ok = c.lru.Peek(key)
 	if ok {
 		c.lock.Unlock()
 		return previous, true, false
 	}
 	evicted = c.lru.Add(key, value)
-	if c.onEvictedCB != nil && evicted {
+	if onEvicted != nil && evicted {
 		k, v = c.evictedKeys[0], c.evictedVals[0]
 		c.evictedKeys, c.evictedVals = c.evictedKeys[:0], c.evictedVals[:0]
 	}
 	c.lock.Unlock()
-	if c.onEvictedCB != nil && evicted {
-		c.onEvictedCB(k, v)
+	if onEvicted != nil && evicted {
+		onEvicted(k, v)
 	}
 	return
 }
@@ -175,15 +310,16 @@ func (c *Cache[K, V]) PeekOrAdd(key K, value V) (previous V, ok, evicted bool) {
 func (c *Cache[K, V]) Remove(key K) (present bool) {
 	var k K
 	var v V
-	c.lock.Lock()
+	c.acquire()
+	onEvicted := c.onEvictedCB
 	present = c.lru.Remove(key)
-	if c.onEvictedCB != nil && present {
+	if onEvicted != nil && present {
 		k, v = c.evictedKeys[0], c.evictedVals[0]
 		c.evictedKeys, c.evictedVals = c.evictedKeys[:0], c.evictedVals[:0]
 	}
 	c.lock.Unlock()
-	if c.onEvictedCB != nil && present {
-		c.onEvictedCB(k, v)
+	if onEvicted != nil && present {
+		onEvicted(k, v)
 	}
 	return
 }
@@ -192,16 +328,38 @@ func (c *Cache[K, V]) Remove(key K) (present bool) {
 func (c *Cache[K, V]) Resize(size int) (evicted int) {
 	var ks []K
 	var vs []V
-	c.lock.Lock()
+	c.acquire()
+	onEvicted := c.onEvictedCB
 	evicted = c.lru.Resize(size)
-	if c.onEvictedCB != nil && evicted > 0 {
+	if onEvicted != nil && evicted > 0 {
 		ks, vs = c.evictedKeys, c.evictedVals
 		c.initEvictBuffers()
 	}
 	c.lock.Unlock()
-	if c.onEvictedCB != nil && evicted > 0 {
+	if onEvicted != nil && evicted > 0 {
 		for i := 0; i < len(ks); i++ {
-			c.onEvictedCB(ks[i], vs[i])
+			onEvicted(ks[i], vs[i])
+		}
+	}
+	return evicted
+}
+
+// EvictTo removes the oldest entries until at most target entries are left,
+// without changing the size of the cache, and returns the number removed.
+func (c *Cache[K, V]) EvictTo(target int) (evicted int) {
+	var ks []K
+	var vs []V
+	c.acquire()
+	onEvicted := c.onEvictedCB
+	evicted = c.lru.EvictTo(target)
+	if onEvicted != nil && evicted > 0 {
+		ks, vs = c.evictedKeys, c.evictedVals
+		c.initEvictBuffers()
+	}
+	c.lock.Unlock()
+	if onEvicted != nil && evicted > 0 {
+		for i := 0; i < len(ks); i++ {
+			onEvicted(ks[i], vs[i])
 		}
 	}
 	return evicted
@@ -211,46 +369,88 @@ func (c *Cache[K, V]) Resize(size int) (evicted int) {
 func (c *Cache[K, V]) RemoveOldest() (key K, value V, ok bool) {
 	var k K
 	var v V
-	c.lock.Lock()
+	c.acquire()
+	onEvicted := c.onEvictedCB
 	key, value, ok = c.lru.RemoveOldest()
-	if c.onEvictedCB != nil && ok {
+	if onEvicted != nil && ok {
 		k, v = c.evictedKeys[0], c.evictedVals[0]
 		c.evictedKeys, c.evictedVals = c.evictedKeys[:0], c.evictedVals[:0]
 	}
 	c.lock.Unlock()
-	if c.onEvictedCB != nil && ok {
-		c.onEvictedCB(k, v)
+	if onEvicted != nil && ok {
+		onEvicted(k, v)
 	}
 	return
 }
 
 // GetOldest returns the oldest entry
 func (c *Cache[K, V]) GetOldest() (key K, value V, ok bool) {
-	c.lock.RLock()
+	c.acquire()
+	onEvicted := c.onEvictedCB
 	key, value, ok = c.lru.GetOldest()
-	c.lock.RUnlock()
+	ks, vs := c.takeEvicted()
+	c.lock.Unlock()
+	callEvicted(onEvicted, ks, vs)
 	return
 }
 
 // Keys returns a slice of the keys in the cache, from oldest to newest.
 func (c *Cache[K, V]) Keys() []K {
-	c.lock.RLock()
+	c.acquire()
+	onEvicted := c.onEvictedCB
 	keys := c.lru.Keys()
-	c.lock.RUnlock()
+	ks, vs := c.takeEvicted()
+	c.lock.Unlock()
+	callEvicted(onEvicted, ks, vs)
 	return keys
 }
 
 // Values returns a slice of the values in the cache, from oldest to newest.
 func (c *Cache[K, V]) Values() []V {
-	c.lock.RLock()
+	c.acquire()
+	onEvicted := c.onEvictedCB
 	values := c.lru.Values()
-	c.lock.RUnlock()
+	ks, vs := c.takeEvicted()
+	c.lock.Unlock()
+	callEvicted(onEvicted, ks, vs)
 	return values
 }
 
+// SnapshotRange calls f for each entry which has not expired, from oldest to
+// newest, until f returns false. The entries are copied while the lock is
+// held and f is called after it has been released, so f sees a point-in-time
+// copy and does not block other operations on the cache, which it may call.
+func (c *Cache[K, V]) SnapshotRange(f func(key K, value V) bool) {
+	c.acquireRead()
+	entries := c.lru.ColdestEntries(c.lru.Len())
+	c.lock.RUnlock()
+
+	for _, e := range entries {
+		if !f(e.Key, e.Value) {
+			return
+		}
+	}
+}
+
+// Stream sends the entries which have not expired to ch, from oldest to
+// newest, and closes ch when done. Unlike simplelru.LRU.Stream the entries
+// are copied while the lock is held and sent after it has been released, so
+// a slow receiver does not block other operations on the cache, which the
+// receiver may call as well.
+func (c *Cache[K, V]) Stream(ch chan<- simplelru.Entry[K, V]) {
+	c.acquireRead()
+	entries := c.lru.ColdestEntries(c.lru.Len())
+	c.lock.RUnlock()
+
+	defer close(ch)
+	for _, e := range entries {
+		ch <- e
+	}
+}
+
 // Len returns the number of items in the cache.
 func (c *Cache[K, V]) Len() int {
-	c.lock.RLock()
+	c.acquireRead()
 	length := c.lru.Len()
 	c.lock.RUnlock()
 	return length
@@ -258,12 +458,132 @@ func (c *Cache[K, V]) Len() int {
 
 // Returns the number of accessible items in the cache.
 func (c *Cache[K, V]) ItemCount() int {
-	c.lock.RLock()
+	c.acquire()
+	onEvicted := c.onEvictedCB
+	count := c.lru.ItemCount()
+	ks, vs := c.takeEvicted()
+	c.lock.Unlock()
+	callEvicted(onEvicted, ks, vs)
+	return count
+}
+
+// Stats returns the counters of the operations on the cache.
+func (c *Cache[K, V]) Stats() simplelru.Stats {
+	c.acquireRead()
+	defer c.lock.RUnlock()
+	return c.lru.Stats()
+}
+
+// PeakLen returns the largest number of entries the cache has held.
+func (c *Cache[K, V]) PeakLen() int {
+	c.acquireRead()
+	defer c.lock.RUnlock()
+	return c.lru.PeakLen()
+}
+
+// WindowedStats returns the counters of the operations on the cache within
+// the trailing window, see simplelru.LRU.WindowedStats.
+func (c *Cache[K, V]) WindowedStats(window time.Duration) simplelru.Stats {
+	c.acquireRead()
 	defer c.lock.RUnlock()
-	return c.lru.ItemCount()
+	return c.lru.WindowedStats(window)
+}
+
+// WriteMetrics writes the size of the cache, its stats and the time of the
+// next expiry to w, see simplelru.LRU.WriteMetrics. The metrics are taken
+// under the lock, but written to w after it has been released.
+func (c *Cache[K, V]) WriteMetrics(w io.Writer) error {
+	var buf bytes.Buffer
+	c.acquireRead()
+	c.lru.WriteMetrics(&buf)
+	c.lock.RUnlock()
+	_, err := buf.WriteTo(w)
+	return err
 }
 
 // Removes all expired entries from the cache.
 func (c *Cache[K, V]) RemoveExpired() (evicted int) {
-	return c.lru.RemoveExpired()
+	c.acquire()
+	onEvicted := c.onEvictedCB
+	evicted = c.lru.RemoveExpired()
+	ks, vs := c.takeEvicted()
+	c.lock.Unlock()
+	callEvicted(onEvicted, ks, vs)
+	return evicted
+}
+
+// swapLock serializes Swap, so that two swaps of the same caches cannot lock
+// them in opposite order.
+var swapLock sync.Mutex
+
+// Swap atomically exchanges the contents of a and b, while holding the locks
+// of both. Each cache keeps its own eviction callback and options of the
+// wrapper, which apply to the contents it receives.
+func Swap[K comparable, V any](a, b *Cache[K, V]) {
+	if a == b {
+		return
+	}
+	swapLock.Lock()
+	defer swapLock.Unlock()
+	a.acquire()
+	defer a.lock.Unlock()
+	b.acquire()
+	defer b.lock.Unlock()
+
+	a.lru, b.lru = b.lru, a.lru
+	a.lru.SetOnEvict(a.evictCallback())
+	b.lru.SetOnEvict(b.evictCallback())
+	a.lru.SetOnEvent(a.eventCallback())
+	b.lru.SetOnEvent(b.eventCallback())
+}
+
+// eventCallback returns the function to pass to the underlying cache for
+// the subscribers, if there are any.
+func (c *Cache[K, V]) eventCallback() func(simplelru.Event[K, V]) {
+	if len(c.subs) == 0 {
+		return nil
+	}
+	return c.publish
+}
+
+// evictCallback returns the callback to pass to the underlying cache, as set
+// up by NewWithEvictTTL.
+func (c *Cache[K, V]) evictCallback() simplelru.EvictCallback[K, V] {
+	switch {
+	case c.evictQueue != nil:
+		return c.evictQueue.push
+	case c.onEvictedCB != nil:
+		return c.onEvicted
+	}
+	return nil
+}
+
+// OnClose registers a function to be called by Close with the entries of the
+// cache which have not expired, from oldest to newest.
+func (c *Cache[K, V]) OnClose(f func(entries []simplelru.Entry[K, V])) {
+	c.acquire()
+	c.onClose = append(c.onClose, f)
+	c.lock.Unlock()
+}
+
+// Close calls the functions registered with OnClose with a final snapshot of
+// the entries. With WithAsyncEvict it first waits for the pending eviction
+// callbacks and stops their goroutine. Only the first call has any effect.
+func (c *Cache[K, V]) Close() {
+	c.closeOnce.Do(func() {
+		c.acquire()
+		entries := c.lru.ColdestEntries(c.lru.Len())
+		onClose := c.onClose
+		c.onClose = nil
+		queue := c.evictQueue
+		c.lock.Unlock()
+
+		if queue != nil {
+			queue.close()
+		}
+
+		for _, f := range onClose {
+			f(entries)
+		}
+	})
 }
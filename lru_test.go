@@ -4,8 +4,13 @@
 package lru
 
 import (
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/craumix/golang-lru/simplelru"
 )
 
 func BenchmarkLRU_Rand(b *testing.B) {
@@ -325,3 +330,592 @@ func TestLRUTTL(t *testing.T) {
 		t.Errorf("Cache Len() should be 0, since item should have been removed")
 	}
 }
+
+// Test that simplelru options are passed through and Get leaves expired
+// entries in place with WithKeepExpiredOnGet
+func TestLRUKeepExpiredOnGet(t *testing.T) {
+	l, err := NewWithEvictTTL(2, nil, time.Millisecond*10, WithLRUOptions(simplelru.WithKeepExpiredOnGet[int, int]()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	time.Sleep(time.Millisecond * 20)
+	if _, ok := l.Get(1); ok {
+		t.Errorf("expired 1 should be a miss")
+	}
+	if l.Len() != 1 {
+		t.Errorf("expired 1 should have been left in place")
+	}
+	if stats := l.Stats(); stats.ExpiredReads != 1 || stats.Misses != 1 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+
+	if _, err := NewWithEvictTTL(2, nil, 0, WithLRUOptions(simplelru.WithEvictedRecord[int, int](0))); err == nil {
+		t.Errorf("should have failed for an invalid option")
+	}
+}
+
+// Test that the OnClose functions are called once with the live entries
+func TestLRUOnClose(t *testing.T) {
+	l, err := New[int, int](4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var calls int
+	var keys []int
+	l.OnClose(func(entries []simplelru.Entry[int, int]) {
+		calls++
+		for _, e := range entries {
+			keys = append(keys, e.Key)
+		}
+	})
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.Get(1)
+
+	l.Close()
+	l.Close()
+	if calls != 1 {
+		t.Errorf("OnClose function should have been called once: %v", calls)
+	}
+	if len(keys) != 3 || keys[0] != 2 || keys[1] != 3 || keys[2] != 1 {
+		t.Errorf("bad entries: %v", keys)
+	}
+}
+
+// Test that a slow eviction callback does not block the cache with
+// WithAsyncEvict, and that Close waits for the callbacks in order
+func TestLRUAsyncEvict(t *testing.T) {
+	release := make(chan struct{})
+	var evicted []int
+	onEvicted := func(k, v int) {
+		<-release
+		evicted = append(evicted, k)
+	}
+	l, err := NewWithEvictTTL(2, onEvicted, 0, WithAsyncEvict[int, int]())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			l.Add(i, i)
+		}
+	}()
+	go func() {
+		for i := 0; i < 10; i++ {
+			l.Get(i)
+			l.Contains(i)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Add should not wait for the eviction callback")
+	}
+	if l.Len() != 2 {
+		t.Errorf("bad len: %v", l.Len())
+	}
+
+	close(release)
+	l.Close()
+	if len(evicted) != 8 {
+		t.Fatalf("all evictions should have been passed to the callback: %v", evicted)
+	}
+	for i, k := range evicted {
+		if k != i {
+			t.Errorf("evictions should be in order: %v", evicted)
+			break
+		}
+	}
+}
+
+// Test that SnapshotRange yields a copy without holding the lock
+func TestLRUSnapshotRange(t *testing.T) {
+	l, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 128; i++ {
+		l.Add(i, i)
+	}
+
+	stop := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for i := 128; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				l.Add(i, i)
+			}
+		}
+	}()
+
+	var n int
+	prev := -1
+	l.SnapshotRange(func(k, v int) bool {
+		if k != v || k <= prev {
+			t.Errorf("bad entry after %v: %v, %v", prev, k, v)
+		}
+		prev = k
+		n++
+
+		// writers must not be blocked while f runs
+		added := make(chan struct{})
+		go func() {
+			l.Add(-k, -k)
+			close(added)
+		}()
+		select {
+		case <-added:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Add should not be blocked during SnapshotRange")
+		}
+		return n < 64
+	})
+	close(stop)
+	<-writerDone
+
+	if n != 64 {
+		t.Errorf("iteration should have stopped after 64 entries: %v", n)
+	}
+}
+
+// Test that Stream sends all live entries and closes the channel
+func TestLRUStream(t *testing.T) {
+	l, err := New[int, int](16)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		l.Add(i, i)
+	}
+
+	// The receiver may use the cache while receiving
+	ch := make(chan simplelru.Entry[int, int])
+	go l.Stream(ch)
+	next := 4
+	for e := range ch {
+		if e.Key != next {
+			t.Errorf("bad key: %v != %v", e.Key, next)
+		}
+		if v, ok := l.Get(e.Key); !ok || v != e.Value {
+			t.Errorf("bad value of %v: %v, %v", e.Key, v, ok)
+		}
+		next++
+	}
+	if next != 20 {
+		t.Errorf("should have received all entries: %v", next)
+	}
+}
+
+// Test that WriteMetrics writes the metrics of the underlying cache
+func TestLRUWriteMetrics(t *testing.T) {
+	l, err := New[int, int](4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Get(1)
+
+	var buf strings.Builder
+	if err := l.WriteMetrics(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !strings.Contains(buf.String(), "len: 1\n") || !strings.Contains(buf.String(), "hit_rate: 1.000\n") {
+		t.Errorf("bad metrics:\n%s", buf.String())
+	}
+}
+
+// Test that exactly one concurrent caller reserves a missing key
+func TestLRUGetOrReserve(t *testing.T) {
+	l, err := New[int, int](128)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for key := 0; key < 10; key++ {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var reserved int
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, hit, res := l.GetOrReserve(key)
+				mu.Lock()
+				defer mu.Unlock()
+				if hit && v != key*2 {
+					t.Errorf("bad value: %v", v)
+				}
+				if res {
+					reserved++
+					l.Fulfill(key, key*2)
+				}
+			}()
+		}
+		wg.Wait()
+		if reserved != 1 {
+			t.Errorf("exactly one caller should have reserved %v: %v", key, reserved)
+		}
+		if v, ok := l.Get(key); !ok || v != key*2 {
+			t.Errorf("bad value for %v: %v, %v", key, v, ok)
+		}
+	}
+}
+
+// Test that Fulfill passes a value it replaces to the eviction callback
+func TestLRUFulfillReplacing(t *testing.T) {
+	var evicted [][2]int
+	l, err := NewWithEvict(2, func(k, v int) { evicted = append(evicted, [2]int{k, v}) })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, _, reserved := l.GetOrReserve(1); !reserved {
+		t.Fatalf("1 should have been reserved")
+	}
+	// Another caller adds the key meanwhile
+	l.Add(1, 10)
+	l.Fulfill(1, 20)
+	if !reflect.DeepEqual(evicted, [][2]int{{1, 10}}) {
+		t.Errorf("replaced value should have been passed to the callback: %v", evicted)
+	}
+
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.Peek(1)
+	if !reflect.DeepEqual(evicted, [][2]int{{1, 10}, {1, 20}}) {
+		t.Errorf("bad evictions: %v", evicted)
+	}
+}
+
+func TestLRUSwap(t *testing.T) {
+	var evictedA, evictedB []int
+	a, err := NewWithEvict(10, func(k, v int) { evictedA = append(evictedA, k) })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	b, err := NewWithEvict(10, func(k, v int) { evictedB = append(evictedB, k) })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		a.Add(i, i)
+		b.Add(100+i, i)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				for _, c := range []*Cache[int, int]{a, b} {
+					keys := c.Keys()
+					if len(keys) != 10 {
+						t.Errorf("bad keys: %v", keys)
+						return
+					}
+					for _, k := range keys {
+						if (k < 100) != (keys[0] < 100) {
+							t.Errorf("inconsistent keys: %v", keys)
+							return
+						}
+					}
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			a.RemoveExpired()
+			b.RemoveExpired()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		Swap(a, b)
+	}
+	close(done)
+	wg.Wait()
+
+	Swap(a, b)
+	Swap(a, a)
+	if keys := a.Keys(); keys[0] != 100 {
+		t.Errorf("a should hold the contents of b: %v", keys)
+	}
+	if keys := b.Keys(); keys[0] != 0 {
+		t.Errorf("b should hold the contents of a: %v", keys)
+	}
+
+	// Each cache keeps its own eviction callback
+	a.Add(200, 200)
+	b.Add(300, 300)
+	if len(evictedA) != 1 || evictedA[0] != 100 {
+		t.Errorf("bad evictions of a: %v", evictedA)
+	}
+	if len(evictedB) != 1 || evictedB[0] != 0 {
+		t.Errorf("bad evictions of b: %v", evictedB)
+	}
+}
+
+// Test that WithEvictWorkers runs the callback concurrently, and that Close
+// waits for all pending evictions
+func TestLRUEvictWorkers(t *testing.T) {
+	var lock sync.Mutex
+	persisted := make(map[int]int)
+	started := make(chan struct{}, 100)
+	release := make(chan struct{})
+	onEvicted := func(k, v int) {
+		started <- struct{}{}
+		<-release
+		lock.Lock()
+		persisted[k] = v
+		lock.Unlock()
+	}
+	l, err := NewWithEvictTTL(2, onEvicted, 0, WithEvictWorkers[int, int](4, 8))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			l.Add(i, i*10)
+		}
+	}()
+
+	// All workers pick up an entry before any of them finishes
+	for i := 0; i < 4; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("only %d workers run the callback", i)
+		}
+	}
+	// The queue is full, so Add waits for the workers
+	select {
+	case <-done:
+		t.Fatalf("Add should wait for space in the queue")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	close(release)
+	<-done
+	l.Close()
+	if len(persisted) != 48 {
+		t.Fatalf("all evictions should have been passed to the callback: %v", persisted)
+	}
+	for i := 0; i < 48; i++ {
+		if persisted[i] != i*10 {
+			t.Errorf("bad persisted value for %d: %v", i, persisted[i])
+		}
+	}
+
+	if _, err := NewWithEvictTTL(2, onEvicted, 0, WithEvictWorkers[int, int](0, 8)); err == nil {
+		t.Errorf("should fail without workers")
+	}
+	if _, err := NewWithEvictTTL(2, onEvicted, 0, WithEvictWorkers[int, int](1, -1)); err == nil {
+		t.Errorf("should fail with a negative queue size")
+	}
+}
+
+// Test that every subscriber receives all events until it unsubscribes, and
+// that events are dropped for subscribers which do not keep up
+func TestLRUSubscribe(t *testing.T) {
+	l, err := New[int, int](4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	const n = 100
+	var wg sync.WaitGroup
+	for s := 0; s < 3; s++ {
+		ch, unsubscribe := l.Subscribe(2 * n)
+		defer unsubscribe()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			adds, evictions := 0, 0
+			for ev := range ch {
+				if ev.Dropped != 0 {
+					t.Errorf("no events should have been dropped: %+v", ev)
+				}
+				switch ev.Type {
+				case simplelru.EventAdd:
+					if ev.Key != adds || ev.Value != adds*2 {
+						t.Errorf("bad add event: %+v", ev)
+					}
+					adds++
+				case simplelru.EventEvict:
+					evictions++
+				case simplelru.EventPurge:
+					if adds != n || evictions != n-4 {
+						t.Errorf("bad events before purge: %d adds, %d evictions", adds, evictions)
+					}
+					return
+				}
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	writers.Add(1)
+	go func() {
+		defer writers.Done()
+		for i := 0; i < n; i++ {
+			l.Add(i, i*2)
+		}
+	}()
+	for i := 0; i < n; i++ {
+		l.Get(i)
+		l.Keys()
+	}
+	writers.Wait()
+	l.Purge()
+	wg.Wait()
+
+	// A full channel drops events, and unsubscribing closes it
+	ch, unsubscribe := l.Subscribe(1)
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	if ev := <-ch; ev.Type != simplelru.EventAdd || ev.Key != 1 || ev.Dropped != 0 {
+		t.Errorf("bad event: %+v", ev)
+	}
+	l.Remove(1)
+	if ev := <-ch; ev.Type != simplelru.EventRemove || ev.Key != 1 || ev.Dropped != 2 {
+		t.Errorf("bad event: %+v", ev)
+	}
+	unsubscribe()
+	unsubscribe()
+	l.Add(4, 4)
+	if ev, ok := <-ch; ok {
+		t.Errorf("channel should have been closed: %+v", ev)
+	}
+}
+
+func TestLRUSetOnEvict(t *testing.T) {
+	l, err := New[int, int](2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+
+	var first, second []int
+	l.SetOnEvict(func(k, v int) { first = append(first, k) })
+	l.Add(4, 4)
+	l.Remove(3)
+	l.SetOnEvict(func(k, v int) { second = append(second, k) })
+	l.Add(5, 5)
+	l.Add(6, 6)
+	l.SetOnEvict(nil)
+	l.Purge()
+
+	if !reflect.DeepEqual(first, []int{2, 3}) {
+		t.Errorf("bad evictions of the first callback: %v", first)
+	}
+	if !reflect.DeepEqual(second, []int{4}) {
+		t.Errorf("bad evictions of the second callback: %v", second)
+	}
+	if keys := l.Keys(); len(keys) != 0 {
+		t.Errorf("bad keys: %v", keys)
+	}
+
+	// Async callbacks can be set later and replaced as well
+	l, err = NewWithEvictTTL[int, int](1, nil, 0, WithAsyncEvict[int, int]())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var lock sync.Mutex
+	var async []int
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.SetOnEvict(func(k, v int) {
+		lock.Lock()
+		async = append(async, k)
+		lock.Unlock()
+	})
+	l.Add(3, 3)
+	l.SetOnEvict(nil)
+	l.Add(4, 4)
+	l.Close()
+	if !reflect.DeepEqual(async, []int{2}) {
+		t.Errorf("bad async evictions: %v", async)
+	}
+}
+
+// Test that entries removed for having expired by reads are passed to the
+// eviction callback, and that readers removing them may run concurrently
+func TestLRUReadsRemovingExpired(t *testing.T) {
+	var lock sync.Mutex
+	var evicted []int
+	l, err := NewWithEvict(8, func(k, v int) {
+		lock.Lock()
+		evicted = append(evicted, k)
+		lock.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	past := time.Now().Add(-time.Second)
+	for i := 0; i < 4; i++ {
+		l.lru.AddWithExp(i, i, past)
+	}
+	var wg sync.WaitGroup
+	for _, read := range []func(){
+		func() { l.Keys() },
+		func() { l.Values() },
+		func() { l.GetOldest() },
+		func() { l.ItemCount() },
+	} {
+		wg.Add(1)
+		go func(read func()) {
+			defer wg.Done()
+			read()
+		}(read)
+	}
+	wg.Wait()
+	if l.Len() != 0 || len(evicted) != 4 {
+		t.Errorf("all expired entries should have been passed to the callback: %v", evicted)
+	}
+
+	// Expired entries removed by Get do not stay buffered for the
+	// next eviction
+	evicted = nil
+	l.lru.AddWithExp(10, 10, past)
+	l.Get(10)
+	for i := 0; i < 9; i++ {
+		l.Add(i, i)
+	}
+	if !reflect.DeepEqual(evicted, []int{10, 0}) {
+		t.Errorf("bad evictions: %v", evicted)
+	}
+}
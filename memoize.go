@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package lru
+
+// Key2 is a cache key made of two function arguments.
+type Key2[A, B comparable] struct {
+	Arg1 A
+	Arg2 B
+}
+
+// Key3 is a cache key made of three function arguments.
+type Key3[A, B, C comparable] struct {
+	Arg1 A
+	Arg2 B
+	Arg3 C
+}
+
+// Memoize returns a function which looks up the result for its argument in
+// the cache, calling f and adding its result only on a miss.
+func Memoize[A comparable, R any](c *Cache[A, R], f func(A) R) func(A) R {
+	return func(a A) R {
+		if v, ok := c.Get(a); ok {
+			return v
+		}
+		v := f(a)
+		c.Add(a, v)
+		return v
+	}
+}
+
+// Memoize2 is like Memoize for functions of two arguments, which are
+// combined into a cache key by key, e.g. a function returning a Key2.
+func Memoize2[A, B any, K comparable, R any](c *Cache[K, R], key func(A, B) K, f func(A, B) R) func(A, B) R {
+	return func(a A, b B) R {
+		k := key(a, b)
+		if v, ok := c.Get(k); ok {
+			return v
+		}
+		v := f(a, b)
+		c.Add(k, v)
+		return v
+	}
+}
+
+// Memoize3 is like Memoize for functions of three arguments, which are
+// combined into a cache key by key, e.g. a function returning a Key3.
+func Memoize3[A, B, C any, K comparable, R any](c *Cache[K, R], key func(A, B, C) K, f func(A, B, C) R) func(A, B, C) R {
+	return func(a A, b B, cc C) R {
+		k := key(a, b, cc)
+		if v, ok := c.Get(k); ok {
+			return v
+		}
+		v := f(a, b, cc)
+		c.Add(k, v)
+		return v
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package lru
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMemoize(t *testing.T) {
+	c, err := New[int, int](8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	calls := 0
+	square := Memoize(c, func(a int) int {
+		calls++
+		return a * a
+	})
+	if square(3) != 9 || square(3) != 9 || square(4) != 16 {
+		t.Errorf("bad results")
+	}
+	if calls != 2 {
+		t.Errorf("f should have been called once per argument: %v", calls)
+	}
+}
+
+func TestMemoize2(t *testing.T) {
+	c, err := New[Key2[string, int], string](8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	calls := 0
+	repeat := Memoize2(c, func(s string, n int) Key2[string, int] {
+		return Key2[string, int]{s, n}
+	}, func(s string, n int) string {
+		calls++
+		return strings.Repeat(s, n)
+	})
+
+	if v := repeat("a", 3); v != "aaa" {
+		t.Errorf("bad result: %v", v)
+	}
+	if v := repeat("a", 3); v != "aaa" {
+		t.Errorf("bad result: %v", v)
+	}
+	if v := repeat("a", 2); v != "aa" {
+		t.Errorf("bad result: %v", v)
+	}
+	if v := repeat("b", 3); v != "bbb" {
+		t.Errorf("bad result: %v", v)
+	}
+	if calls != 3 {
+		t.Errorf("f should have been called once per argument tuple: %v", calls)
+	}
+	if c.Len() != 3 {
+		t.Errorf("bad len: %v", c.Len())
+	}
+}
+
+func TestMemoize3(t *testing.T) {
+	c, err := New[Key3[int, int, int], int](8)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	calls := 0
+	sum := Memoize3(c, func(a, b, cc int) Key3[int, int, int] {
+		return Key3[int, int, int]{a, b, cc}
+	}, func(a, b, cc int) int {
+		calls++
+		return a + b + cc
+	})
+
+	if sum(1, 2, 3) != 6 || sum(1, 2, 3) != 6 || sum(3, 2, 1) != 6 {
+		t.Errorf("bad results")
+	}
+	if calls != 2 {
+		t.Errorf("f should have been called once per argument tuple: %v", calls)
+	}
+}
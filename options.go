@@ -0,0 +1,76 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package lru
+
+import (
+	"errors"
+
+	"github.com/craumix/golang-lru/simplelru"
+)
+
+// Option configures optional behaviour of a Cache on construction.
+type Option[K comparable, V any] func(*Cache[K, V]) error
+
+// WithLRUOptions passes options to the underlying simplelru.LRU.
+func WithLRUOptions[K comparable, V any](opts ...simplelru.Option[K, V]) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		c.lruOpts = append(c.lruOpts, opts...)
+		return nil
+	}
+}
+
+// WithAsyncEvict makes the cache run the eviction callback on a dedicated
+// goroutine, so that a slow callback does not delay the operation which
+// evicted the entry. Each evicted entry is passed to the callback at most
+// once, one at a time and in the order of eviction. As the callback runs
+// later, the entry may already be gone from the cache, or the key may have
+// been added again. As with simplelru.LRU, the callback is also called with
+// the previous value when a key is updated.
+//
+// Close must be called to wait for the pending callbacks and stop the
+// goroutine; entries evicted after Close are not passed to the callback.
+func WithAsyncEvict[K comparable, V any]() Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		c.asyncEvict = true
+		return nil
+	}
+}
+
+// WithEvictWorkers is like WithAsyncEvict, but runs the eviction callback on
+// a pool of workers goroutines, for callbacks too slow to keep up one at a
+// time, such as writing evicted entries back to slow storage. The workers
+// call the callback concurrently, so entries may be passed to it out of
+// order, and the callback must be safe for concurrent use.
+//
+// If queueSize is positive, at most queueSize evicted entries are kept
+// pending, and operations which evict more wait for the workers while
+// holding the lock of the cache; the callback must then not use the cache.
+// A queueSize of 0 does not limit the queue.
+//
+// Close must be called to wait for the pending callbacks and stop the
+// workers.
+func WithEvictWorkers[K comparable, V any](workers, queueSize int) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if workers <= 0 {
+			return errors.New("must provide a positive number of workers")
+		}
+		if queueSize < 0 {
+			return errors.New("must provide a non-negative queue size")
+		}
+		c.asyncEvict = true
+		c.evictWorkers = workers
+		c.evictLimit = queueSize
+		return nil
+	}
+}
+
+// WithLockStats makes the cache measure how long operations wait for its
+// lock, as returned by LockStats, to diagnose contention. Operations which
+// find the lock free are counted without reading the clock.
+func WithLockStats[K comparable, V any]() Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		c.lockStats = new(lockStats)
+		return nil
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+const (
+	// sketchDepth is the number of counter rows of a countMinSketch.
+	sketchDepth = 4
+
+	// sketchMaxCount is the value at which counters saturate.
+	sketchMaxCount = 15
+
+	// sketchResetFactor times the sketch width is the number of
+	// increments after which all counters are halved.
+	sketchResetFactor = 10
+)
+
+// countMinSketch estimates how often keys have been accessed recently.
+// Counters are halved periodically so that old accesses are forgotten.
+type countMinSketch[K comparable] struct {
+	hash      func(K) uint64
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+// newCountMinSketch returns a sketch suitable for a cache of the given size.
+func newCountMinSketch[K comparable](size int, hash func(K) uint64) *countMinSketch[K] {
+	width := 16
+	for width < size {
+		width *= 2
+	}
+
+	s := &countMinSketch[K]{
+		hash:    hash,
+		mask:    uint64(width - 1),
+		resetAt: width * sketchResetFactor,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// indexes returns the counter position of key in each row.
+func (s *countMinSketch[K]) indexes(key K) (idx [sketchDepth]uint64) {
+	// Mix the hash in case it is poorly distributed,
+	// then derive one position per row by double hashing.
+	h := s.hash(key)
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	step := h>>32 | 1
+	for i := range idx {
+		idx[i] = (h + uint64(i)*step) & s.mask
+	}
+	return idx
+}
+
+// increment records an access of key.
+func (s *countMinSketch[K]) increment(key K) {
+	for i, j := range s.indexes(key) {
+		if s.rows[i][j] < sketchMaxCount {
+			s.rows[i][j]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.resetAt {
+		for i := range s.rows {
+			for j := range s.rows[i] {
+				s.rows[i][j] /= 2
+			}
+		}
+		s.additions /= 2
+	}
+}
+
+// estimate returns the approximate number of recent accesses of key.
+func (s *countMinSketch[K]) estimate(key K) int {
+	count := uint8(sketchMaxCount)
+	for i, j := range s.indexes(key) {
+		if s.rows[i][j] < count {
+			count = s.rows[i][j]
+		}
+	}
+	return int(count)
+}
+
+// admit decides whether a new entry for key may replace the entry that
+// would be evicted to make room for it, by comparing how often both keys
+// have been accessed recently. The victim is chosen like removeOldest does,
+// by the eviction policy if there is one.
+func (c *LRU[K, V]) admit(key K) bool {
+	victim, ok := c.getOldest(true)
+	if !ok || c.KeyHasExpired(victim.key) {
+		return true
+	}
+	if c.policy != nil {
+		victim = c.victim(victim)
+	}
+
+	freq := c.admission.estimate(key)
+	if c.WasRecentlyEvicted(key) {
+		freq++
+	}
+	return freq >= c.admission.estimate(victim.key)
+}
@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func hashInt(k int) uint64 {
+	return uint64(k)
+}
+
+// zipfScanHitRatio replays a Zipfian workload interleaved with a scan of
+// keys that are never requested again, adding entries on misses.
+func zipfScanHitRatio(t *testing.T, l *LRU[int, int]) float64 {
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 1, 10000)
+
+	var hit, miss int
+	scan := 1000000
+	for i := 0; i < 200000; i++ {
+		k := int(zipf.Uint64())
+		if i%3 == 0 {
+			k = scan
+			scan++
+		}
+		if _, ok := l.Get(k); ok {
+			hit++
+		} else {
+			miss++
+			l.Add(k, k)
+		}
+	}
+	ratio := float64(hit) / float64(hit+miss)
+	t.Logf("hit: %d miss: %d ratio: %f", hit, miss, ratio)
+	return ratio
+}
+
+func TestLRU_AdmissionHitRatio(t *testing.T) {
+	plain, err := NewLRU[int, int](128, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	admitting, err := NewLRUWithEvictTTL(128, nil, 0, WithAdmission[int, int](hashInt))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	plainRatio := zipfScanHitRatio(t, plain)
+	admittingRatio := zipfScanHitRatio(t, admitting)
+	if admittingRatio <= plainRatio {
+		t.Errorf("admission should improve the hit ratio: %f <= %f", admittingRatio, plainRatio)
+	}
+}
+
+func TestLRU_Admission(t *testing.T) {
+	l, err := NewLRUWithEvictTTL(3, nil, 0, WithAdmission[int, int](hashInt))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	for i := 0; i < 5; i++ {
+		l.Get(1)
+	}
+
+	// Admission is skipped below capacity
+	l.Add(2, 2)
+	l.Add(3, 3)
+	if l.Len() != 3 {
+		t.Fatalf("all entries should have been admitted: %v", l.Keys())
+	}
+
+	// 4 has been seen less often than the victim 1
+	if l.Add(4, 4) || l.Contains(4) || !l.Contains(1) {
+		t.Errorf("4 should have been rejected: %v", l.Keys())
+	}
+
+	for i := 0; i < 5; i++ {
+		l.Add(4, 4)
+	}
+	if !l.Contains(4) || l.Contains(1) {
+		t.Errorf("4 should have been admitted in place of 1: %v", l.Keys())
+	}
+
+	if _, err := NewLRUWithEvictTTL(3, nil, 0, WithAdmission[int, int](nil)); err == nil {
+		t.Errorf("should have failed without a hash function")
+	}
+}
+
+func TestCountMinSketch(t *testing.T) {
+	s := newCountMinSketch(16, hashInt)
+	for i := 0; i < 5; i++ {
+		s.increment(1)
+	}
+	s.increment(2)
+
+	if n := s.estimate(1); n != 5 {
+		t.Errorf("bad estimate for 1: %v", n)
+	}
+	if n := s.estimate(2); n != 1 {
+		t.Errorf("bad estimate for 2: %v", n)
+	}
+	if n := s.estimate(3); n != 0 {
+		t.Errorf("bad estimate for 3: %v", n)
+	}
+
+	// Counters are halved once enough accesses have been recorded
+	for i := 0; i < s.resetAt; i++ {
+		s.increment(100 + i%8)
+	}
+	if n := s.estimate(1); n > 2 {
+		t.Errorf("estimate for 1 should have decayed: %v", n)
+	}
+}
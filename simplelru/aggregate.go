@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+// ordered is the constraint of types which support the < operator, like
+// cmp.Ordered in newer versions of Go.
+type ordered interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64 |
+		~string
+}
+
+// compareOrdered compares a and b for MaxValue and MinValue.
+func compareOrdered[V ordered](a, b V) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// MaxValue returns the entry with the largest value among those which have
+// not expired, without updating their "recently used"-ness. Of equal values
+// the least recently used one is returned. ok is false if there is none.
+func MaxValue[K comparable, V ordered](c *LRU[K, V]) (key K, value V, ok bool) {
+	return MaxValueFunc(c, compareOrdered[V])
+}
+
+// MinValue is like MaxValue for the smallest value.
+func MinValue[K comparable, V ordered](c *LRU[K, V]) (key K, value V, ok bool) {
+	return MinValueFunc(c, compareOrdered[V])
+}
+
+// MaxValueFunc is like MaxValue for values which are compared with compare,
+// which returns a negative number if a < b, zero if a == b and a positive
+// number if a > b.
+func MaxValueFunc[K comparable, V any](c *LRU[K, V], compare func(a, b V) int) (key K, value V, ok bool) {
+	return c.extremum(func(a, b V) bool { return compare(a, b) > 0 })
+}
+
+// MinValueFunc is like MinValue for values which are compared with compare,
+// see MaxValueFunc.
+func MinValueFunc[K comparable, V any](c *LRU[K, V], compare func(a, b V) int) (key K, value V, ok bool) {
+	return c.extremum(func(a, b V) bool { return compare(a, b) < 0 })
+}
+
+// extremum returns the oldest live entry whose value no other value is
+// better than.
+func (c *LRU[K, V]) extremum(better func(a, b V) bool) (key K, value V, ok bool) {
+	for ent := c.evictList.back(); ent != nil; ent = ent.prevEntry() {
+		if c.KeyHasExpired(ent.key) {
+			continue
+		}
+		if !ok || better(ent.value, value) {
+			key, value, ok = ent.key, ent.value, true
+		}
+	}
+	return
+}
+
+// ValueCounts returns how many keys hold each value among the entries which
+// have not expired, without updating their "recently used"-ness. Values
+// counted more than once suggest caching by value instead. This walks the
+// whole cache, so it is O(n).
+func ValueCounts[K comparable, V comparable](c *LRU[K, V]) map[V]int {
+	counts := make(map[V]int)
+	for ent := c.evictList.back(); ent != nil; ent = ent.prevEntry() {
+		if !c.KeyHasExpired(ent.key) {
+			counts[ent.value]++
+		}
+	}
+	return counts
+}
+
+// DuplicateValueGroups is like ValueCounts for values which are not
+// comparable, which are grouped by the string hash returns for them. Only
+// groups of more than one key are returned, each from oldest to newest. This
+// walks the whole cache, so it is O(n).
+func DuplicateValueGroups[K comparable, V any](c *LRU[K, V], hash func(V) string) map[string][]K {
+	groups := make(map[string][]K)
+	for ent := c.evictList.back(); ent != nil; ent = ent.prevEntry() {
+		if !c.KeyHasExpired(ent.key) {
+			h := hash(ent.value)
+			groups[h] = append(groups[h], ent.key)
+		}
+	}
+	for h, keys := range groups {
+		if len(keys) < 2 {
+			delete(groups, h)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,119 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxMinValue(t *testing.T) {
+	l, err := NewLRU[string, int](8, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, _, ok := MaxValue(l); ok {
+		t.Errorf("empty cache should have no max value")
+	}
+	if _, _, ok := MinValue(l); ok {
+		t.Errorf("empty cache should have no min value")
+	}
+
+	l.Add("a", 3)
+	l.Add("b", 7)
+	l.Add("c", 1)
+	l.Add("d", 7)
+	l.Add("e", 1)
+	l.AddWithExp("f", 9, time.Now().Add(-time.Second))
+	l.AddWithExp("g", 0, time.Now().Add(-time.Second))
+
+	// Ties return the least recently used entry
+	if k, v, ok := MaxValue(l); !ok || k != "b" || v != 7 {
+		t.Errorf("bad max: %v, %v, %v", k, v, ok)
+	}
+	if k, v, ok := MinValue(l); !ok || k != "c" || v != 1 {
+		t.Errorf("bad min: %v, %v, %v", k, v, ok)
+	}
+	if k, _, _ := l.GetOldest(); k != "a" {
+		t.Errorf("recent-ness should not have been updated: %v", k)
+	}
+}
+
+func TestMaxMinValueFunc(t *testing.T) {
+	l, err := NewLRU[int, []string](8, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, []string{"b"})
+	l.Add(2, []string{"c", "a"})
+	l.Add(3, []string{"a"})
+
+	compare := func(a, b []string) int {
+		return strings.Compare(strings.Join(a, ""), strings.Join(b, ""))
+	}
+	if k, _, ok := MaxValueFunc(l, compare); !ok || k != 2 {
+		t.Errorf("bad max: %v", k)
+	}
+	if k, _, ok := MinValueFunc(l, compare); !ok || k != 3 {
+		t.Errorf("bad min: %v", k)
+	}
+}
+
+func TestValueCounts(t *testing.T) {
+	l, err := NewLRU[string, int](8, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if counts := ValueCounts(l); len(counts) != 0 {
+		t.Errorf("bad counts: %v", counts)
+	}
+
+	l.Add("a", 1)
+	l.Add("b", 2)
+	l.Add("c", 1)
+	l.Add("d", 1)
+	l.Add("e", 3)
+	l.AddWithExp("f", 3, time.Now().Add(-time.Second))
+	l.Get("a")
+
+	counts := ValueCounts(l)
+	if len(counts) != 3 || counts[1] != 3 || counts[2] != 1 || counts[3] != 1 {
+		t.Errorf("bad counts: %v", counts)
+	}
+	if keys := l.Keys(); keys[len(keys)-1] != "a" {
+		t.Errorf("counting should not update recent-ness: %v", keys)
+	}
+}
+
+func TestDuplicateValueGroups(t *testing.T) {
+	l, err := NewLRU[int, []string](8, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	hash := func(v []string) string { return strings.Join(v, ",") }
+	if groups := DuplicateValueGroups(l, hash); len(groups) != 0 {
+		t.Errorf("bad groups: %v", groups)
+	}
+
+	l.Add(1, []string{"a", "b"})
+	l.Add(2, []string{"c"})
+	l.Add(3, []string{"a", "b"})
+	l.Add(4, []string{"c"})
+	l.Add(5, []string{"d"})
+	l.Add(6, []string{"a", "b"})
+	l.AddWithExp(7, []string{"d"}, time.Now().Add(-time.Second))
+
+	groups := DuplicateValueGroups(l, hash)
+	if len(groups) != 2 {
+		t.Fatalf("bad groups: %v", groups)
+	}
+	if keys := groups["a,b"]; len(keys) != 3 || keys[0] != 1 || keys[1] != 3 || keys[2] != 6 {
+		t.Errorf("bad group of a,b: %v", keys)
+	}
+	if keys := groups["c"]; len(keys) != 2 || keys[0] != 2 || keys[1] != 4 {
+		t.Errorf("bad group of c: %v", keys)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import "time"
+
+// Config holds the settings of a cache which are plain values, as returned
+// by Config and accepted by NewFromConfig. Options taking functions or
+// state, such as WithClock, WithSizer, WithAdmission or WithEvictionPolicy,
+// are not included and must be passed to NewFromConfig again.
+type Config struct {
+	// Size is the capacity of the cache.
+	Size int
+	// TTL is the expiry of entries added without one, see
+	// NewLRUWithEvictTTL.
+	TTL time.Duration
+	// FIFO is set by WithFIFO.
+	FIFO bool
+	// KeepExpiredOnGet is set by WithKeepExpiredOnGet.
+	KeepExpiredOnGet bool
+	// RejectZeroKey is set by WithRejectZeroKey.
+	RejectZeroKey bool
+	// ExpiryGranularity is set by WithExpiryGranularity.
+	ExpiryGranularity time.Duration
+	// MaxTTL is set by WithMaxTTL, or 0 if expiries are not limited.
+	MaxTTL time.Duration
+	// RejectOverMaxTTL is set by WithRejectOverMaxTTL.
+	RejectOverMaxTTL bool
+	// WriteThrottle is set by WithWriteThrottle.
+	WriteThrottle time.Duration
+	// EvictedRecordSize is set by WithEvictedRecord, or 0 if evicted keys
+	// are not recorded.
+	EvictedRecordSize int
+	// StatsWindowSize is set by WithStatsWindow, or 0 if there are no
+	// windowed stats.
+	StatsWindowSize int
+}
+
+// Config returns the current settings of the cache, which NewFromConfig
+// turns into a new cache behaving the same.
+func (c *LRU[K, V]) Config() Config {
+	cfg := Config{
+		Size:              c.size,
+		TTL:               c.itemTTL,
+		FIFO:              c.fifo,
+		KeepExpiredOnGet:  c.keepExpiredOnGet,
+		RejectZeroKey:     c.rejectZeroKey,
+		ExpiryGranularity: c.expiryGranularity,
+		MaxTTL:            c.maxTTL,
+		RejectOverMaxTTL:  c.rejectOverMaxTTL,
+		WriteThrottle:     c.writeThrottle,
+	}
+	if c.evicted != nil {
+		cfg.EvictedRecordSize = c.evicted.size
+	}
+	if c.events != nil {
+		cfg.StatsWindowSize = len(c.events.times)
+	}
+	return cfg
+}
+
+// NewFromConfig constructs an empty cache with the settings of cfg, the
+// eviction callback onEvict and further options, which are applied after
+// those of cfg.
+func NewFromConfig[K comparable, V any](cfg Config, onEvict EvictCallback[K, V], opts ...Option[K, V]) (*LRU[K, V], error) {
+	var cfgOpts []Option[K, V]
+	if cfg.FIFO {
+		cfgOpts = append(cfgOpts, WithFIFO[K, V]())
+	}
+	if cfg.KeepExpiredOnGet {
+		cfgOpts = append(cfgOpts, WithKeepExpiredOnGet[K, V]())
+	}
+	if cfg.RejectZeroKey {
+		cfgOpts = append(cfgOpts, WithRejectZeroKey[K, V]())
+	}
+	if cfg.ExpiryGranularity != 0 {
+		cfgOpts = append(cfgOpts, WithExpiryGranularity[K, V](cfg.ExpiryGranularity))
+	}
+	if cfg.MaxTTL != 0 {
+		cfgOpts = append(cfgOpts, WithMaxTTL[K, V](cfg.MaxTTL))
+	}
+	if cfg.RejectOverMaxTTL {
+		cfgOpts = append(cfgOpts, WithRejectOverMaxTTL[K, V]())
+	}
+	if cfg.WriteThrottle != 0 {
+		cfgOpts = append(cfgOpts, WithWriteThrottle[K, V](cfg.WriteThrottle))
+	}
+	if cfg.EvictedRecordSize != 0 {
+		cfgOpts = append(cfgOpts, WithEvictedRecord[K, V](cfg.EvictedRecordSize))
+	}
+	if cfg.StatsWindowSize != 0 {
+		cfgOpts = append(cfgOpts, WithStatsWindow[K, V](cfg.StatsWindowSize))
+	}
+	return NewLRUWithEvictTTL(cfg.Size, onEvict, cfg.TTL, append(cfgOpts, opts...)...)
+}
@@ -0,0 +1,83 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLRU_Config(t *testing.T) {
+	l, err := NewLRU[int, int](4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if cfg := l.Config(); cfg != (Config{Size: 4}) {
+		t.Errorf("bad default config: %+v", cfg)
+	}
+
+	clock := newFakeClock()
+	l, err = NewLRUWithEvictTTL(2, nil, time.Minute,
+		WithClock[int, int](clock.Now),
+		WithFIFO[int, int](),
+		WithKeepExpiredOnGet[int, int](),
+		WithRejectZeroKey[int, int](),
+		WithExpiryGranularity[int, int](time.Second),
+		WithMaxTTL[int, int](time.Hour),
+		WithRejectOverMaxTTL[int, int](),
+		WithWriteThrottle[int, int](time.Millisecond),
+		WithEvictedRecord[int, int](8),
+		WithStatsWindow[int, int](16))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	cfg := l.Config()
+	expected := Config{
+		Size:              2,
+		TTL:               time.Minute,
+		FIFO:              true,
+		KeepExpiredOnGet:  true,
+		RejectZeroKey:     true,
+		ExpiryGranularity: time.Second,
+		MaxTTL:            time.Hour,
+		RejectOverMaxTTL:  true,
+		WriteThrottle:     time.Millisecond,
+		EvictedRecordSize: 8,
+		StatsWindowSize:   16,
+	}
+	if cfg != expected {
+		t.Errorf("bad config: %+v", cfg)
+	}
+
+	c, err := NewFromConfig[int, int](cfg, nil, WithClock[int, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if cfg := c.Config(); cfg != expected {
+		t.Errorf("config should round-trip: %+v", cfg)
+	}
+
+	// Both caches behave the same
+	for _, l := range []*LRU[int, int]{l, c} {
+		l.Add(0, 0)
+		l.Add(1, 1)
+		l.Add(2, 2)
+		l.Get(1)
+		l.Add(3, 3)
+		if keys := l.Keys(); !reflect.DeepEqual(keys, []int{2, 3}) {
+			t.Errorf("bad keys: %v", keys)
+		}
+		if !l.WasRecentlyEvicted(1) {
+			t.Errorf("1 should have been recorded as evicted")
+		}
+		if exp := l.ExpiryForKey(3); !exp.Round(0).Equal(clock.Now().Add(time.Minute)) {
+			t.Errorf("bad expiry: %v", exp)
+		}
+	}
+
+	if _, err := NewFromConfig[int, int](Config{}, nil); err == nil {
+		t.Errorf("should fail without a size")
+	}
+}
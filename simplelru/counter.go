@@ -0,0 +1,33 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import "time"
+
+// IncrAndGet increments the counter stored for key and returns its new value,
+// which makes the cache usable as a fixed window rate limiter. A counter which
+// is not in the cache, or has expired, starts at 1 and expires after window;
+// incrementing it later does not change its expiry. If window is not positive
+// the TTL of the cache is used. Returns 0 if the key is rejected by the cache.
+func IncrAndGet[K comparable](c *LRU[K, int64], key K, window time.Duration) (count int64) {
+	if ent, ok := c.items[key]; ok {
+		if !c.KeyHasExpired(key) {
+			ent.value++
+			c.emit(EventUpdate, key, ent.value)
+			c.promote(ent)
+			return ent.value
+		}
+		c.dropElement(ent, removeExpired)
+	}
+
+	var expiry time.Time
+	if window > 0 {
+		expiry = c.deadlineNow().Add(window)
+	}
+	c.AddWithExp(key, 1, expiry)
+	if _, ok := c.items[key]; !ok {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,49 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIncrAndGet(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(4, nil, 0, WithClock[string, int64](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := int64(1); i <= 3; i++ {
+		if n := IncrAndGet(l, "a", time.Minute); n != i {
+			t.Errorf("bad count: %v != %v", n, i)
+		}
+		clock.Advance(time.Second * 10)
+	}
+	if n := IncrAndGet(l, "b", time.Minute); n != 1 {
+		t.Errorf("bad count for b: %v", n)
+	}
+
+	// The window is fixed from the first increment
+	expiry := l.ExpiryForKey("a")
+	clock.Advance(time.Second * 25)
+	if n := IncrAndGet(l, "a", time.Minute); n != 4 {
+		t.Errorf("bad count: %v", n)
+	}
+	if !l.ExpiryForKey("a").Equal(expiry) {
+		t.Errorf("expiry should not have changed: %v != %v", l.ExpiryForKey("a"), expiry)
+	}
+
+	// A new window starts after the rollover
+	clock.Advance(time.Second * 10)
+	if n := IncrAndGet(l, "a", time.Minute); n != 1 {
+		t.Errorf("count should have restarted: %v", n)
+	}
+	if !l.ExpiryForKey("a").Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("bad expiry: %v", l.ExpiryForKey("a"))
+	}
+	if n := IncrAndGet(l, "b", time.Minute); n != 2 {
+		t.Errorf("bad count for b: %v", n)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+// AddWithDeps adds a value to the cache like Add, and records that it
+// depends on the entries for deps, replacing dependencies recorded earlier.
+// Whenever one of those entries is removed, for any reason, the entry for
+// key is removed as well and passed to the eviction callback, which may in
+// turn remove its own dependents. Expired dependencies only cascade once
+// they are actually removed. Keys of deps which are not in the cache, and
+// key itself, are ignored; cycles are allowed. Dependencies are only
+// tracked for entries added with AddWithDeps, at the cost of some
+// bookkeeping on every removal. Returns true if an eviction occurred.
+func (c *LRU[K, V]) AddWithDeps(key K, value V, deps []K) (evicted bool) {
+	evicted = c.Add(key, value)
+	if _, ok := c.items[key]; !ok {
+		return evicted
+	}
+
+	c.unlinkDeps(key)
+	for _, dep := range deps {
+		if _, ok := c.items[dep]; !ok || dep == key || containsKey(c.itemDeps[key], dep) {
+			continue
+		}
+		if c.itemDeps == nil {
+			c.itemDeps = make(map[K][]K)
+			c.dependents = make(map[K][]K)
+		}
+		c.itemDeps[key] = append(c.itemDeps[key], dep)
+		c.dependents[dep] = append(c.dependents[dep], key)
+	}
+	return evicted
+}
+
+// unlinkDeps forgets the dependencies of key.
+func (c *LRU[K, V]) unlinkDeps(key K) {
+	for _, dep := range c.itemDeps[key] {
+		dependents := removeKey(c.dependents[dep], key)
+		if len(dependents) == 0 {
+			delete(c.dependents, dep)
+		} else {
+			c.dependents[dep] = dependents
+		}
+	}
+	delete(c.itemDeps, key)
+}
+
+// removeDependents removes the entries depending on the removed key.
+// The removed entries are no longer in the cache, so cycles end there.
+func (c *LRU[K, V]) removeDependents(key K) {
+	c.unlinkDeps(key)
+	dependents := c.dependents[key]
+	delete(c.dependents, key)
+	for _, k := range dependents {
+		if ent, ok := c.items[k]; ok {
+			c.dropElement(ent, removeManual)
+		}
+	}
+}
+
+// dependsOn reports whether key depends on dep, directly or through other
+// entries.
+func (c *LRU[K, V]) dependsOn(key, dep K) bool {
+	seen := make(map[K]struct{})
+	pending := []K{key}
+	for len(pending) > 0 {
+		k := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, d := range c.itemDeps[k] {
+			if d == dep {
+				return true
+			}
+			if _, ok := seen[d]; !ok {
+				seen[d] = struct{}{}
+				pending = append(pending, d)
+			}
+		}
+	}
+	return false
+}
+
+// renameDeps moves the dependencies of oldKey, and its dependents,
+// over to newKey.
+func (c *LRU[K, V]) renameDeps(oldKey, newKey K) {
+	rename := func(keys []K) {
+		for i, k := range keys {
+			if k == oldKey {
+				keys[i] = newKey
+			}
+		}
+	}
+	if deps, ok := c.itemDeps[oldKey]; ok {
+		for _, dep := range deps {
+			rename(c.dependents[dep])
+		}
+		delete(c.itemDeps, oldKey)
+		c.itemDeps[newKey] = deps
+	}
+	if dependents, ok := c.dependents[oldKey]; ok {
+		for _, k := range dependents {
+			rename(c.itemDeps[k])
+		}
+		delete(c.dependents, oldKey)
+		c.dependents[newKey] = dependents
+	}
+}
+
+// containsKey reports whether keys contains key.
+func containsKey[K comparable](keys []K, key K) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// removeKey removes all occurrences of key from keys in place.
+func removeKey[K comparable](keys []K, key K) []K {
+	n := 0
+	for _, k := range keys {
+		if k != key {
+			keys[n] = k
+			n++
+		}
+	}
+	var zero K
+	for i := n; i < len(keys); i++ {
+		keys[i] = zero
+	}
+	return keys[:n]
+}
@@ -0,0 +1,182 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLRU_AddWithDeps(t *testing.T) {
+	var evicted []string
+	l, err := NewLRU(8, func(k string, v int) { evicted = append(evicted, k) })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add("a", 1)
+	l.Add("x", 0)
+	l.AddWithDeps("b", 2, []string{"a"})
+	l.AddWithDeps("c", 3, []string{"a", "b", "missing"})
+	l.AddWithDeps("d", 4, []string{"c"})
+	l.AddWithDeps("y", 0, []string{"x"})
+
+	// Removing a cascades to everything derived from it
+	l.Remove("a")
+	sort.Strings(evicted)
+	if !reflect.DeepEqual(evicted, []string{"a", "b", "c", "d"}) {
+		t.Errorf("bad cascade: %v", evicted)
+	}
+	if !reflect.DeepEqual(l.Keys(), []string{"x", "y"}) {
+		t.Errorf("independent keys should be untouched: %v", l.Keys())
+	}
+
+	// Dependencies are replaced by re-adding, and dropped with the entry
+	l.Add("a", 1)
+	l.AddWithDeps("b", 2, []string{"a"})
+	l.AddWithDeps("b", 2, []string{"x"})
+	l.Remove("a")
+	if !l.Contains("b") {
+		t.Errorf("b should no longer depend on a")
+	}
+	l.Remove("y")
+	evicted = nil
+	l.Remove("x")
+	sort.Strings(evicted)
+	if !reflect.DeepEqual(evicted, []string{"b", "x"}) {
+		t.Errorf("bad cascade: %v", evicted)
+	}
+	if len(l.itemDeps) != 0 || len(l.dependents) != 0 {
+		t.Errorf("dependencies should have been forgotten: %v, %v", l.itemDeps, l.dependents)
+	}
+}
+
+func TestLRU_AddWithDepsExpiry(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL[string, int](8, nil, 0, WithClock[string, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.AddWithExp("a", 1, clock.Now().Add(time.Second))
+	l.AddWithDeps("b", 2, []string{"a"})
+	l.AddWithDeps("c", 3, []string{"b"})
+	l.Add("d", 4)
+	clock.Advance(time.Second * 2)
+
+	if n := l.RemoveExpired(); n != 1 {
+		t.Errorf("only a should have expired: %v", n)
+	}
+	if !reflect.DeepEqual(l.Keys(), []string{"d"}) {
+		t.Errorf("dependents of a should have been removed: %v", l.Keys())
+	}
+}
+
+func TestLRU_AddWithDepsCycle(t *testing.T) {
+	var evicted []string
+	l, err := NewLRU(8, func(k string, v int) { evicted = append(evicted, k) })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add("a", 1)
+	l.AddWithDeps("b", 2, []string{"a"})
+	l.AddWithDeps("c", 3, []string{"b", "c"})
+	l.AddWithDeps("a", 1, []string{"c"})
+	l.Add("d", 4)
+
+	evicted = nil
+	l.Remove("b")
+	sort.Strings(evicted)
+	if !reflect.DeepEqual(evicted, []string{"a", "b", "c"}) {
+		t.Errorf("each entry of the cycle should be removed once: %v", evicted)
+	}
+	if !reflect.DeepEqual(l.Keys(), []string{"d"}) {
+		t.Errorf("bad keys: %v", l.Keys())
+	}
+
+	// Renamed entries keep their dependencies
+	l.Add("a", 1)
+	l.AddWithDeps("b", 2, []string{"a"})
+	l.Rename("a", "z")
+	l.Remove("z")
+	if l.Contains("b") {
+		t.Errorf("b should have been removed with z")
+	}
+}
+
+// Test that iterations survive the next entry being removed as a dependent
+func TestLRU_AddWithDepsIteration(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL[string, int](8, nil, 0, WithClock[string, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add("x", 0)
+	l.AddWithExp("a", 1, clock.Now().Add(time.Second))
+	l.AddWithDeps("b", 2, []string{"a"})
+	l.AddWithExp("c", 3, clock.Now().Add(time.Second))
+	l.AddWithDeps("d", 4, []string{"c"})
+	l.Add("y", 0)
+	clock.Advance(time.Second * 2)
+
+	if !reflect.DeepEqual(l.Keys(), []string{"x", "y"}) {
+		t.Errorf("bad keys: %v", l.Keys())
+	}
+
+	l.Add("a", 1)
+	l.AddWithDeps("b", 2, []string{"a"})
+	l.Add("z", 0)
+	if n := l.PurgeWhere(func(k string, v int) bool { return v > 0 }, true); n != 1 {
+		t.Errorf("bad removed count: %v", n)
+	}
+	if !reflect.DeepEqual(l.Keys(), []string{"x", "y", "z"}) {
+		t.Errorf("bad keys: %v", l.Keys())
+	}
+}
+
+// Test that Rename refuses to replace an entry the renamed one depends on
+func TestLRU_AddWithDepsRename(t *testing.T) {
+	l, err := NewLRU[int, int](8, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(2, 20)
+	l.AddWithDeps(1, 10, []int{2})
+	if l.Rename(1, 2) {
+		t.Errorf("1 depends on 2, so it should not have been renamed")
+	}
+	l.Add(3, 30)
+	l.AddWithDeps(4, 40, []int{3})
+	l.AddWithDeps(5, 50, []int{4})
+	if l.Rename(5, 3) {
+		t.Errorf("5 depends on 3 through 4, so it should not have been renamed")
+	}
+	if l.Len() != 5 {
+		t.Errorf("refused renames should not change the cache: %v", l.Keys())
+	}
+	if v, ok := l.Get(2); !ok || v != 20 {
+		t.Errorf("bad value of 2: %v, %v", v, ok)
+	}
+	if !l.Remove(2) || l.Contains(1) {
+		t.Errorf("removing 2 should have removed 1")
+	}
+
+	// Renaming onto an entry depending on the renamed one is fine
+	l.Add(6, 60)
+	l.AddWithDeps(7, 70, []int{6})
+	if !l.Rename(6, 7) {
+		t.Errorf("6 should have been renamed")
+	}
+	if v, ok := l.Get(7); !ok || v != 60 {
+		t.Errorf("bad value of 7: %v, %v", v, ok)
+	}
+	if !reflect.DeepEqual(l.Keys(), []int{3, 4, 5, 7}) {
+		t.Errorf("bad keys: %v", l.Keys())
+	}
+}
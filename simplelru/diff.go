@@ -0,0 +1,38 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+// Diff compares two sets of entries by key, e.g. taken from a cache at
+// different times. It returns the keys only found after, the keys only found
+// before, and the keys found in both whose values differ. Added and changed
+// keys are in the order of after, removed keys in the order of before.
+func Diff[K comparable, V comparable](before, after []Entry[K, V]) (added, removed, changed []K) {
+	return DiffFunc(before, after, func(a, b V) bool { return a == b })
+}
+
+// DiffFunc is like Diff for values that are not comparable, using equal to
+// tell whether two values are the same.
+func DiffFunc[K comparable, V any](before, after []Entry[K, V], equal func(a, b V) bool) (added, removed, changed []K) {
+	previous := make(map[K]V, len(before))
+	for _, e := range before {
+		previous[e.Key] = e.Value
+	}
+
+	current := make(map[K]struct{}, len(after))
+	for _, e := range after {
+		current[e.Key] = struct{}{}
+		if v, ok := previous[e.Key]; !ok {
+			added = append(added, e.Key)
+		} else if !equal(v, e.Value) {
+			changed = append(changed, e.Key)
+		}
+	}
+
+	for _, e := range before {
+		if _, ok := current[e.Key]; !ok {
+			removed = append(removed, e.Key)
+		}
+	}
+	return
+}
@@ -0,0 +1,46 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := []Entry[int, string]{{Key: 1, Value: "a"}, {Key: 2, Value: "b"}, {Key: 3, Value: "c"}}
+	after := []Entry[int, string]{{Key: 3, Value: "c"}, {Key: 2, Value: "B"}, {Key: 4, Value: "d"}, {Key: 5, Value: "e"}}
+
+	added, removed, changed := Diff(before, after)
+	if !reflect.DeepEqual(added, []int{4, 5}) {
+		t.Errorf("bad added: %v", added)
+	}
+	if !reflect.DeepEqual(removed, []int{1}) {
+		t.Errorf("bad removed: %v", removed)
+	}
+	if !reflect.DeepEqual(changed, []int{2}) {
+		t.Errorf("bad changed: %v", changed)
+	}
+
+	added, removed, changed = Diff(before, before)
+	if added != nil || removed != nil || changed != nil {
+		t.Errorf("identical entries should have no differences: %v %v %v", added, removed, changed)
+	}
+
+	added, removed, changed = Diff(nil, before)
+	if len(added) != 3 || removed != nil || changed != nil {
+		t.Errorf("everything should have been added: %v %v %v", added, removed, changed)
+	}
+}
+
+func TestDiffFunc(t *testing.T) {
+	before := []Entry[string, []byte]{{Key: "a", Value: []byte("1")}, {Key: "b", Value: []byte("2")}}
+	after := []Entry[string, []byte]{{Key: "a", Value: []byte("1")}, {Key: "b", Value: []byte("3")}}
+
+	added, removed, changed := DiffFunc(before, after, bytes.Equal)
+	if added != nil || removed != nil || !reflect.DeepEqual(changed, []string{"b"}) {
+		t.Errorf("only b should have changed: %v %v %v", added, removed, changed)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+// EventType is the kind of change to the cache reported by an Event.
+type EventType int
+
+const (
+	// EventAdd is reported when a key is added to the cache.
+	EventAdd EventType = iota
+	// EventUpdate is reported when the value of a key in the cache is
+	// replaced, with the new value.
+	EventUpdate
+	// EventRemove is reported when an entry is removed by the caller.
+	EventRemove
+	// EventEvict is reported when an entry is evicted to make room.
+	EventEvict
+	// EventExpire is reported when an expired entry is removed.
+	EventExpire
+	// EventPurge is reported for each entry removed by Purge.
+	EventPurge
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventAdd:
+		return "add"
+	case EventUpdate:
+		return "update"
+	case EventRemove:
+		return "remove"
+	case EventEvict:
+		return "evict"
+	case EventExpire:
+		return "expire"
+	case EventPurge:
+		return "purge"
+	}
+	return "unknown"
+}
+
+// Event describes a change to an entry of the cache.
+type Event[K comparable, V any] struct {
+	Type  EventType
+	Key   K
+	Value V
+}
+
+// SetOnEvent sets a function which is called with every change to the
+// entries of the cache, or removes it if f is nil. Unlike the eviction
+// callback it tells why an entry was removed, and also reports additions.
+func (c *LRU[K, V]) SetOnEvent(f func(Event[K, V])) {
+	c.onEvent = f
+}
+
+// emit reports an event to the function set with SetOnEvent.
+func (c *LRU[K, V]) emit(t EventType, key K, value V) {
+	if c.onEvent != nil {
+		c.onEvent(Event[K, V]{Type: t, Key: key, Value: value})
+	}
+}
@@ -4,6 +4,8 @@
 
 package simplelru
 
+import "time"
+
 // entry is an LRU entry
 type entry[K comparable, V any] struct {
 	// Next and previous pointers in the doubly-linked list of elements.
@@ -21,6 +23,17 @@ type entry[K comparable, V any] struct {
 
 	// The value stored with this element.
 	value V
+
+	// The time the entry was added or last updated.
+	created time.Time
+}
+
+// nextEntry returns the next list element or nil.
+func (e *entry[K, V]) nextEntry() *entry[K, V] {
+	if n := e.next; e.list != nil && n != &e.list.root {
+		return n
+	}
+	return nil
 }
 
 // prevEntry returns the previous list element or nil.
@@ -53,6 +66,14 @@ func newList[K comparable, V any]() *lruList[K, V] { return new(lruList[K, V]).i
 // The complexity is O(1).
 func (l *lruList[K, V]) length() int { return l.len }
 
+// front returns the first element of list l or nil if the list is empty.
+func (l *lruList[K, V]) front() *entry[K, V] {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.next
+}
+
 // back returns the last element of list l or nil if the list is empty.
 func (l *lruList[K, V]) back() *entry[K, V] {
 	if l.len == 0 {
@@ -116,6 +137,18 @@ func (l *lruList[K, V]) pushFront(k K, v V) *entry[K, V] {
 	return l.insertValue(k, v, &l.root)
 }
 
+// pushFrontEntry reuses the removed element e, or allocates a new one if e is
+// nil, to insert k and v at the front of list l and returns it.
+func (l *lruList[K, V]) pushFrontEntry(e *entry[K, V], k K, v V) *entry[K, V] {
+	if e == nil {
+		return l.pushFront(k, v)
+	}
+	l.lazyInit()
+	e.key = k
+	e.value = v
+	return l.insert(e, &l.root)
+}
+
 // moveToFront moves element e to the front of list l.
 // If e is not an element of l, the list is not modified.
 // The element must not be nil.
@@ -5,20 +5,147 @@ package simplelru
 
 import (
 	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
 	"time"
 )
 
+// ErrZeroKey is returned when adding an entry with the zero value as key to
+// a cache created with WithRejectZeroKey.
+var ErrZeroKey = errors.New("key is the zero value")
+
+// ErrExpiryTooLate is returned when adding an entry with an expiry beyond
+// the max TTL to a cache created with WithRejectOverMaxTTL.
+var ErrExpiryTooLate = errors.New("expiry exceeds the max TTL")
+
+// ErrNotAdmitted is returned when a new entry is rejected by the admission
+// policy of a cache created with WithAdmission.
+var ErrNotAdmitted = errors.New("entry not admitted")
+
 // EvictCallback is used to get a callback when a cache entry is evicted
 type EvictCallback[K comparable, V any] func(key K, value V)
 
+// Entry is a cache entry as returned by methods exposing the cache contents.
+// Expiry is zero if the entry does not expire.
+type Entry[K comparable, V any] struct {
+	Key    K
+	Value  V
+	Expiry time.Time
+}
+
 // LRU implements a non-thread safe fixed size LRU cache
 type LRU[K comparable, V any] struct {
 	size         int
 	evictList    *lruList[K, V]
 	items        map[K]*entry[K, V]
 	onEvict      EvictCallback[K, V]
+	onEvent      func(Event[K, V])
 	itemTTL      time.Duration
 	itemExpiries map[K]time.Time
+
+	// itemReads holds the number of reads left for entries
+	// added with AddWithMaxReads.
+	itemReads map[K]int
+
+	// overrides holds the values set with OverrideOnce.
+	overrides map[K]V
+
+	// itemDeps holds the keys each entry added with AddWithDeps depends
+	// on, and dependents the reverse.
+	itemDeps   map[K][]K
+	dependents map[K][]K
+
+	// minExpiry is a lower bound of all expiries in itemExpiries,
+	// used to skip scanning for expired entries when none can exist.
+	minExpiry time.Time
+
+	// evicted records the keys of recently evicted or expired entries,
+	// if enabled by WithEvictedRecord.
+	evicted *LRU[K, struct{}]
+
+	// admission estimates access frequencies to decide whether new
+	// entries may evict old ones, if enabled by WithAdmission.
+	admission *countMinSketch[K]
+
+	// sizer returns the size of a value in bytes, if set by WithSizer,
+	// and bytesEvicted sums the sizes of all evicted and expired values.
+	sizer        func(V) int64
+	bytesEvicted int64
+
+	// rng is used to pick random keys.
+	rng *rand.Rand
+
+	// beforeEvict may rescue entries about to be evicted,
+	// if set by WithBeforeEvict.
+	beforeEvict func(K, V) bool
+
+	// keepExpiredOnGet makes Get leave expired entries in place,
+	// if set by WithKeepExpiredOnGet.
+	keepExpiredOnGet bool
+
+	// expiryGranularity is the interval expiries are rounded up to,
+	// if set by WithExpiryGranularity.
+	expiryGranularity time.Duration
+
+	// rejectZeroKey prevents adding entries with the zero value as key,
+	// if set by WithRejectZeroKey.
+	rejectZeroKey bool
+
+	// validateKey returns an error for keys which may not be added,
+	// if set by WithKeyValidator.
+	validateKey func(K) error
+
+	// now returns the current time, set by WithClock, and lastNow is the
+	// latest time returned by deadlineNow.
+	now     func() time.Time
+	lastNow time.Time
+
+	// policy chooses the entries to evict instead of the list order,
+	// if set by WithEvictionPolicy.
+	policy EvictionPolicy[K]
+
+	// writeThrottle is the interval within which updates of a key are
+	// coalesced, if set by WithWriteThrottle, and itemWrites holds the
+	// time of the last update of each key which was not coalesced.
+	writeThrottle time.Duration
+	itemWrites    map[K]time.Time
+
+	// observeVictim is called with each entry chosen to make room,
+	// if set by WithVictimObserver.
+	observeVictim func(K, EvictReason)
+
+	// fifo keeps entries in insertion order, if set by WithFIFO.
+	fifo bool
+
+	// cloneValue copies values handed out by the cache,
+	// if set by WithValueCloner.
+	cloneValue func(V) V
+
+	// isTombstone tells values which mark a key as absent,
+	// if set by WithTombstone.
+	isTombstone func(V) bool
+
+	// maxTTL limits how far in the future expiries may be,
+	// if set by WithMaxTTL.
+	maxTTL time.Duration
+	// rejectOverMaxTTL rejects expiries beyond maxTTL instead of clamping
+	// them, if set by WithRejectOverMaxTTL.
+	rejectOverMaxTTL bool
+
+	// reserved holds the keys reserved by GetOrReserve. Reservations
+	// are not entries, so they are kept when entries are removed.
+	reserved map[K]struct{}
+
+	stats  Stats
+	events *eventRing
+	// peakLen is the largest number of entries the cache has held.
+	peakLen int
+
+	// entryPool holds removed list elements for reuse, to avoid allocating
+	// a new element for each entry added under high turnover.
+	entryPool sync.Pool
 }
 
 // NewLRU constructs an LRU of the given size
@@ -26,31 +153,129 @@ func NewLRU[K comparable, V any](size int, onEvict EvictCallback[K, V]) (*LRU[K,
 	return NewLRUWithEvictTTL[K, V](size, onEvict, 0)
 }
 
-func NewLRUWithEvictTTL[K comparable, V any](size int, onEvict EvictCallback[K, V], itemTTL time.Duration) (*LRU[K, V], error) {
+// NewLRUWithEvictTTL constructs an LRU of the given size with the given
+// eviction callback, ttl for items and options.
+func NewLRUWithEvictTTL[K comparable, V any](size int, onEvict EvictCallback[K, V], itemTTL time.Duration, opts ...Option[K, V]) (*LRU[K, V], error) {
 	if size <= 0 {
 		return nil, errors.New("must provide a positive size")
 	}
 
 	c := &LRU[K, V]{
-		size:         size,
-		evictList:    newList[K, V](),
-		items:        make(map[K]*entry[K, V]),
-		onEvict:      onEvict,
-		itemTTL:      itemTTL,
-		itemExpiries: make(map[K]time.Time),
+		size:      size,
+		evictList: newList[K, V](),
+		items:     make(map[K]*entry[K, V]),
+		onEvict:   onEvict,
+		itemTTL:   itemTTL,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// NewLRUWithEntries constructs an LRU of the given size holding the given
+// entries, which are ordered from oldest to newest as returned by
+// ColdestEntries. Entries which have expired are dropped, and of the others
+// only the newest size entries are kept, without calling onEvict.
+func NewLRUWithEntries[K comparable, V any](size int, onEvict EvictCallback[K, V], entries []Entry[K, V]) (*LRU[K, V], error) {
+	c, err := NewLRU(size, onEvict)
+	if err != nil {
+		return nil, err
+	}
+
+	now := c.now()
+	live := func(e Entry[K, V]) bool {
+		return e.Expiry.IsZero() || !e.Expiry.Before(now)
+	}
+	first, n := len(entries), 0
+	for first > 0 && n < size {
+		first--
+		if live(entries[first]) {
+			n++
+		}
+	}
+	for _, e := range entries[first:] {
+		if live(e) {
+			c.AddWithExp(e.Key, e.Value, e.Expiry)
+		}
 	}
 	return c, nil
 }
 
+// NewFromMapOrdered constructs an LRU of the given size holding the entries
+// of m, ordered from oldest to newest as the keys in keyOrder. Keys of
+// keyOrder which are not in m are skipped, as are repeated keys after their
+// first occurrence. Keys of m which are not in keyOrder are added after
+// those of keyOrder, in map iteration order, so they are the most recently
+// used ones. Only the newest size entries are kept, without calling onEvict.
+func NewFromMapOrdered[K comparable, V any](size int, m map[K]V, keyOrder []K, onEvict EvictCallback[K, V]) (*LRU[K, V], error) {
+	ordered := make(map[K]struct{}, len(m))
+	entries := make([]Entry[K, V], 0, len(m))
+	for _, k := range keyOrder {
+		if _, ok := ordered[k]; ok {
+			continue
+		}
+		if v, ok := m[k]; ok {
+			ordered[k] = struct{}{}
+			entries = append(entries, Entry[K, V]{Key: k, Value: v})
+		}
+	}
+	for k, v := range m {
+		if _, ok := ordered[k]; !ok {
+			entries = append(entries, Entry[K, V]{Key: k, Value: v})
+		}
+	}
+	return NewLRUWithEntries(size, onEvict, entries)
+}
+
 // Purge is used to completely clear the cache.
 func (c *LRU[K, V]) Purge() {
-	for k, v := range c.items {
-		if c.onEvict != nil {
+	if c.onEvict != nil {
+		for k, v := range c.items {
 			c.onEvict(k, v.value)
 		}
+	}
+	c.removeAll()
+}
+
+// PurgeAndCollect clears the cache like Purge, but instead of passing the
+// entries to the eviction callback it returns the values of all entries that
+// had not expired, from oldest to newest. Expired values are dropped.
+func (c *LRU[K, V]) PurgeAndCollect() []V {
+	values := make([]V, 0, c.evictList.length())
+	for ent := c.evictList.back(); ent != nil; ent = ent.prevEntry() {
+		if !c.KeyHasExpired(ent.key) {
+			values = append(values, ent.value)
+		}
+	}
+	c.removeAll()
+	return values
+}
+
+// removeAll removes all entries without calling the eviction callback.
+// They are reported as purged to the function set with SetOnEvent.
+func (c *LRU[K, V]) removeAll() {
+	if c.onEvent != nil {
+		for ent := c.evictList.back(); ent != nil; ent = ent.prevEntry() {
+			c.emit(EventPurge, ent.key, ent.value)
+		}
+	}
+	for k := range c.items {
+		if c.policy != nil {
+			c.policy.RecordRemove(k)
+		}
 		delete(c.items, k)
-		delete(c.itemExpiries, k)
 	}
+	c.itemExpiries = nil
+	c.itemReads = nil
+	c.itemWrites = nil
+	c.overrides = nil
+	c.itemDeps = nil
+	c.dependents = nil
+	c.minExpiry = time.Time{}
 	c.evictList.init()
 }
 
@@ -61,45 +286,298 @@ func (c *LRU[K, V]) Add(key K, value V) (evicted bool) {
 
 // Add adds a value to the cache allows for specific time to expire value.
 // If provided time IsZero() the caches own TTL will be used (if available).
+// Updating an existing key sets its expiry the same way.
 // Returns true if an eviction occurred.
 func (c *LRU[K, V]) AddWithExp(key K, value V, expiry time.Time) (evicted bool) {
+	evicted, _ = c.add(key, value, expiry, false)
+	return evicted
+}
+
+// add adds or updates an entry for AddWithExp and returns the error
+// rejecting it, if any. If keepOld is set, the replaced value is not passed
+// to the eviction callback as the caller takes it.
+func (c *LRU[K, V]) add(key K, value V, expiry time.Time, keepOld bool) (evicted bool, err error) {
+	if err := c.checkKey(key); err != nil {
+		return false, err
+	}
+	if err := c.checkExpiry(expiry); err != nil {
+		return false, err
+	}
+	if c.admission != nil {
+		c.admission.increment(key)
+	}
+
 	// Check for existing item
 	if ent, ok := c.items[key]; ok {
-		c.evictList.moveToFront(ent)
-		if c.onEvict != nil {
+		if c.throttleWrite(key) {
+			ent.value = value
+			c.emit(EventUpdate, key, value)
+			return false, nil
+		}
+		c.promote(ent)
+		if c.policy != nil {
+			c.policy.RecordAccess(key)
+		}
+		if c.onEvict != nil && !keepOld {
 			c.onEvict(key, ent.value)
 		}
 		ent.value = value
-		return false
+		ent.created = c.now()
+		// The read limit belongs to the replaced value
+		delete(c.itemReads, key)
+		if !expiry.IsZero() {
+			c.setExpiry(key, expiry)
+		} else if c.itemTTL > 0 {
+			c.setExpiry(key, c.deadlineNow().Add(c.itemTTL))
+		}
+		c.emit(EventUpdate, key, value)
+		return false, nil
+	}
+
+	// Make room for the new item first, so that the evicted
+	// list element can be reused for it
+	var ent *entry[K, V]
+	evict := c.evictList.length() >= c.size
+	if evict && c.admission != nil {
+		if !c.admit(key) {
+			return false, ErrNotAdmitted
+		}
+		evict = c.evictList.length() >= c.size
+	}
+	if evict {
+		ent = c.removeOldest()
+	}
+	if ent == nil {
+		ent = c.pooledEntry()
 	}
 
 	// Add new item
-	ent := c.evictList.pushFront(key, value)
+	ent = c.evictList.pushFrontEntry(ent, key, value)
+	ent.created = c.now()
 	c.items[key] = ent
+	if c.policy != nil {
+		c.policy.RecordInsert(key)
+	}
+	if c.writeThrottle > 0 {
+		c.throttleWrite(key)
+	}
 	if !expiry.IsZero() {
-		c.itemExpiries[key] = expiry
+		c.setExpiry(key, expiry)
 	} else if c.itemTTL > 0 {
-		c.itemExpiries[key] = time.Now().Add(c.itemTTL)
+		c.setExpiry(key, c.deadlineNow().Add(c.itemTTL))
 	}
+	c.emit(EventAdd, key, value)
 
-	evict := c.evictList.length() > c.size
 	// Verify size not exceeded
-	if evict {
-		c.removeOldest()
+	if c.evictList.length() > c.size {
+		c.releaseEntry(c.removeOldest())
+	}
+	if n := c.evictList.length(); n > c.peakLen {
+		c.peakLen = n
+	}
+	return evict, nil
+}
+
+// AddReplacing adds a value to the cache like AddWithExp, but if key is
+// already in the cache and has not expired, it returns the previous value
+// instead of passing it to the eviction callback, and sets the expiry as for
+// a new entry. With WithWriteThrottle, an update within the throttle interval
+// only replaces the value and keeps the expiry, as with AddWithExp. evicted
+// reports whether another entry was evicted to make room, which does not
+// happen when replacing a value. If the entry is rejected, the error is
+// returned as by AddChecked and value is not stored.
+func (c *LRU[K, V]) AddReplacing(key K, value V, expiry time.Time) (old V, existed, evicted bool, err error) {
+	ent, ok := c.items[key]
+	if ok && c.KeyHasExpired(key) {
+		c.dropElement(ent, removeExpired)
+		ok = false
+	}
+	if ok {
+		old = ent.value
+	}
+	if evicted, err = c.add(key, value, expiry, true); err != nil {
+		var zero V
+		return zero, false, false, err
+	}
+	return old, ok, evicted, nil
+}
+
+// AddChecked adds a value to the cache like AddWithExp, but returns an error
+// instead of silently dropping the entry if its key or expiry is rejected,
+// or ErrNotAdmitted if admission rejects it.
+func (c *LRU[K, V]) AddChecked(key K, value V, expiry time.Time) (evicted bool, err error) {
+	return c.add(key, value, expiry, false)
+}
+
+// AddWithMaxReads adds a value to the cache which is removed once it has been
+// returned by Get maxReads times, or never if maxReads is 0. Peek and
+// Contains do not count as reads. Updating the key otherwise, as with Add,
+// removes the limit. Returns true if an eviction occurred.
+func (c *LRU[K, V]) AddWithMaxReads(key K, value V, maxReads int) (evicted bool) {
+	evicted = c.AddWithExp(key, value, time.Time{})
+	if _, ok := c.items[key]; !ok {
+		return evicted
+	}
+	if maxReads > 0 {
+		if c.itemReads == nil {
+			c.itemReads = make(map[K]int)
+		}
+		c.itemReads[key] = maxReads
+	} else {
+		delete(c.itemReads, key)
 	}
-	return evict
+	return evicted
+}
+
+// promote makes an accessed entry the most recently used one,
+// unless the cache keeps entries in insertion order.
+func (c *LRU[K, V]) promote(ent *entry[K, V]) {
+	if !c.fifo {
+		c.evictList.moveToFront(ent)
+	}
+}
+
+// clone returns a copy of a value handed out by the cache,
+// if a cloner was set with WithValueCloner.
+func (c *LRU[K, V]) clone(v V) V {
+	if c.cloneValue != nil {
+		return c.cloneValue(v)
+	}
+	return v
+}
+
+// throttleWrite reports whether a write of key is to be coalesced with an
+// earlier one within the write throttle interval, and otherwise records
+// the time of the write.
+func (c *LRU[K, V]) throttleWrite(key K) bool {
+	if c.writeThrottle <= 0 {
+		return false
+	}
+	now := c.deadlineNow()
+	if last, ok := c.itemWrites[key]; ok && now.Sub(last) < c.writeThrottle {
+		return true
+	}
+	if c.itemWrites == nil {
+		c.itemWrites = make(map[K]time.Time)
+	}
+	c.itemWrites[key] = now
+	return false
+}
+
+// OverrideOnce makes the next Get of key which finds its entry return value
+// instead of the cached value, for example to test consumers of the cache.
+// The cached value and expiry are not changed, and Peek returns the cached
+// value. The override is dropped with the entry, and ignored if key is not
+// in the cache or has expired.
+func (c *LRU[K, V]) OverrideOnce(key K, value V) {
+	if _, ok := c.items[key]; !ok || c.KeyHasExpired(key) {
+		return
+	}
+	if c.overrides == nil {
+		c.overrides = make(map[K]V)
+	}
+	c.overrides[key] = value
+}
+
+// checkKey returns an error if key may not be added to the cache.
+func (c *LRU[K, V]) checkKey(key K) error {
+	var zero K
+	if c.rejectZeroKey && key == zero {
+		return ErrZeroKey
+	}
+	if c.validateKey != nil {
+		return c.validateKey(key)
+	}
+	return nil
+}
+
+// checkExpiry returns ErrExpiryTooLate if expiry is beyond the max TTL and
+// the cache was created with WithRejectOverMaxTTL.
+func (c *LRU[K, V]) checkExpiry(expiry time.Time) error {
+	if !c.rejectOverMaxTTL || expiry.IsZero() {
+		return nil
+	}
+	if expiry.After(c.deadlineNow().Add(c.maxTTL)) {
+		return ErrExpiryTooLate
+	}
+	return nil
 }
 
 // Get looks up a key's value from the cache.
 func (c *LRU[K, V]) Get(key K) (value V, ok bool) {
-	if ent, ok := c.items[key]; ok && !c.KeyHasExpired(key) {
+	if r := c.Lookup(key); r.State == LookupHit {
+		return r.Value, true
+	}
+	return
+}
+
+// LookupState tells the outcome of Lookup.
+type LookupState int
+
+const (
+	// LookupMiss is returned for keys which are not in the cache.
+	LookupMiss LookupState = iota
+	// LookupHit is returned for entries which have not expired.
+	LookupHit
+	// LookupExpired is returned for entries which have expired.
+	LookupExpired
+	// LookupTombstone is returned for entries which have not expired and
+	// whose value is a tombstone, see WithTombstone. Value is not set.
+	LookupTombstone
+)
+
+// LookupResult is the result of Lookup. Value is set for hits and, with
+// the stale value, for expired entries.
+type LookupResult[K comparable, V any] struct {
+	Key   K
+	Value V
+	State LookupState
+}
+
+// Lookup looks up a key like Get, but tells an expired entry apart from a
+// missing one and returns the stale value of an expired entry, for example
+// to serve it while it is being refreshed. The expired entry is removed
+// unless the cache was created with WithKeepExpiredOnGet.
+func (c *LRU[K, V]) Lookup(key K) LookupResult[K, V] {
+	if c.admission != nil {
+		c.admission.increment(key)
+	}
+	if ent, ok := c.items[key]; ok {
 		if !c.KeyHasExpired(key) {
-			c.evictList.moveToFront(ent)
-			return ent.value, true
+			if c.isTombstone != nil && c.isTombstone(ent.value) {
+				c.record(eventMiss)
+				c.promote(ent)
+				return LookupResult[K, V]{Key: key, State: LookupTombstone}
+			}
+			c.record(eventHit)
+			value := c.clone(ent.value)
+			if v, ok := c.overrides[key]; ok {
+				value = v
+				delete(c.overrides, key)
+			}
+			if n, ok := c.itemReads[key]; ok {
+				if n <= 1 {
+					c.dropElement(ent, removeExpired)
+					return LookupResult[K, V]{Key: key, Value: value, State: LookupHit}
+				}
+				c.itemReads[key] = n - 1
+			}
+			c.promote(ent)
+			if c.policy != nil {
+				c.policy.RecordAccess(key)
+			}
+			return LookupResult[K, V]{Key: key, Value: value, State: LookupHit}
+		}
+		c.record(eventExpiredRead)
+		c.record(eventMiss)
+		res := LookupResult[K, V]{Key: key, Value: c.clone(ent.value), State: LookupExpired}
+		if !c.keepExpiredOnGet {
+			c.dropElement(ent, removeExpired)
 		}
-		c.removeElement(ent)
+		return res
 	}
-	return
+	c.record(eventMiss)
+	return LookupResult[K, V]{Key: key}
 }
 
 // Contains checks if a key is in the cache, without updating the recent-ness
@@ -107,23 +585,66 @@ func (c *LRU[K, V]) Get(key K) (value V, ok bool) {
 func (c *LRU[K, V]) Contains(key K) (ok bool) {
 	if ent, ok := c.items[key]; ok {
 		if !c.KeyHasExpired(key) {
+			c.record(eventContainsHit)
 			return true
 		}
-		c.removeElement(ent)
+		c.dropElement(ent, removeExpired)
 	}
 
+	c.record(eventContainsMiss)
 	return
 }
 
 // Peek returns the key value (or undefined if not found) without updating
 // the "recently used"-ness of the key.
 func (c *LRU[K, V]) Peek(key K) (value V, ok bool) {
-	var ent *entry[K, V]
-	if ent, ok = c.items[key]; ok {
+	if ent, ok := c.items[key]; ok {
 		if !c.KeyHasExpired(key) {
-			return ent.value, true
+			c.record(eventPeekHit)
+			return c.clone(ent.value), true
+		}
+		c.dropElement(ent, removeExpired)
+	}
+	c.record(eventPeekMiss)
+	return
+}
+
+// PeekMulti returns the values of the given keys which are in the cache and
+// have not expired. Unlike Peek it neither removes expired entries nor counts
+// the lookups in the stats, so it does not modify the cache at all.
+func (c *LRU[K, V]) PeekMulti(keys []K) map[K]V {
+	values := make(map[K]V, len(keys))
+	for _, key := range keys {
+		if ent, ok := c.items[key]; ok && !c.KeyHasExpired(key) {
+			values[key] = c.clone(ent.value)
 		}
-		c.removeElement(ent)
+	}
+	return values
+}
+
+// CreatedAt returns the time the entry for key was added, or last updated
+// by adding it again, as updates replace the entry. Updates coalesced by
+// WithWriteThrottle do not change it. ok is false if key is not in the cache
+// or has expired.
+func (c *LRU[K, V]) CreatedAt(key K) (created time.Time, ok bool) {
+	if ent, ok := c.items[key]; ok && !c.KeyHasExpired(key) {
+		return ent.created, true
+	}
+	return
+}
+
+// ContainsPhysical checks if a key is stored in the cache, whether or not it
+// has expired, without updating the recent-ness or deleting it.
+func (c *LRU[K, V]) ContainsPhysical(key K) (ok bool) {
+	_, ok = c.items[key]
+	return ok
+}
+
+// PeekPhysical returns the value stored for key, whether or not it has
+// expired, without updating the "recently used"-ness or deleting it.
+func (c *LRU[K, V]) PeekPhysical(key K) (value V, ok bool) {
+	if ent, ok := c.items[key]; ok {
+		return c.clone(ent.value), true
 	}
 	return
 }
@@ -132,19 +653,171 @@ func (c *LRU[K, V]) Peek(key K) (value V, ok bool) {
 // key was contained.
 func (c *LRU[K, V]) Remove(key K) (present bool) {
 	if ent, ok := c.items[key]; ok {
-		defer c.removeElement(ent)
-		if !c.KeyHasExpired(key) {
-			return true
+		if c.KeyHasExpired(key) {
+			c.dropElement(ent, removeExpired)
+			return false
 		}
+		c.dropElement(ent, removeManual)
+		return true
+	}
+	return
+}
+
+// Expire removes the provided key from the cache as if it had expired,
+// returning if the key was contained and had not expired yet. Unlike Remove
+// the entry is removed immediately as an expired one: it is counted as an
+// expiration in the stats, reported as EventExpire, recorded by
+// WithEvictedRecord and passed to the eviction callback.
+func (c *LRU[K, V]) Expire(key K) (present bool) {
+	if ent, ok := c.items[key]; ok {
+		present = !c.KeyHasExpired(key)
+		c.dropElement(ent, removeExpired)
 	}
 	return
 }
 
-// RemoveOldest removes the oldest item from the cache.
+// PurgeWhere removes the entries which have not expired and for which pred
+// returns true, and returns the number of entries removed. The removed
+// entries are only passed to the eviction callback if fireCallback is true.
+func (c *LRU[K, V]) PurgeWhere(pred func(key K, value V) bool, fireCallback bool) (removed int) {
+	if !fireCallback {
+		onEvict := c.onEvict
+		c.onEvict = nil
+		defer func() { c.onEvict = onEvict }()
+	}
+
+	// removing an entry with dependents may restart the walk, so the
+	// visited entries are remembered to not pass them to pred twice
+	var seen map[K]struct{}
+	if c.itemDeps != nil {
+		seen = make(map[K]struct{})
+	}
+
+	var next *entry[K, V]
+	for ent := c.evictList.back(); ent != nil; ent = next {
+		next = ent.prevEntry()
+		if c.KeyHasExpired(ent.key) {
+			continue
+		}
+		if seen != nil {
+			if _, ok := seen[ent.key]; ok {
+				continue
+			}
+			seen[ent.key] = struct{}{}
+		}
+		if pred(ent.key, ent.value) {
+			c.dropElement(ent, removeManual)
+			removed++
+			next = c.resume(next)
+		}
+	}
+	return removed
+}
+
+// RetainFunc removes the entries which have not expired and for which keep
+// returns false, passing them to the eviction callback, and returns the
+// number of entries removed. keep is called once for each entry, from
+// oldest to newest, and must not modify the cache.
+func (c *LRU[K, V]) RetainFunc(keep func(key K, value V) bool) (removed int) {
+	return c.PurgeWhere(func(key K, value V) bool { return !keep(key, value) }, true)
+}
+
+// RangeAndReap calls f for each entry which has not expired, from oldest to
+// newest, until f returns false, without updating their "recently
+// used"-ness. Expired entries passed on the way are removed, as by
+// RemoveExpired, and their number is returned. f must not modify the cache.
+func (c *LRU[K, V]) RangeAndReap(f func(key K, value V) bool) (reaped int) {
+	// removing an entry with dependents may restart the walk, so the
+	// visited entries are remembered to not pass them to f twice
+	var seen map[K]struct{}
+	if c.itemDeps != nil {
+		seen = make(map[K]struct{})
+	}
+
+	var next *entry[K, V]
+	for ent := c.evictList.back(); ent != nil; ent = next {
+		next = ent.prevEntry()
+		if c.KeyHasExpired(ent.key) {
+			c.dropElement(ent, removeExpired)
+			reaped++
+			next = c.resume(next)
+			continue
+		}
+		if seen != nil {
+			if _, ok := seen[ent.key]; ok {
+				continue
+			}
+			seen[ent.key] = struct{}{}
+		}
+		if !f(ent.key, c.clone(ent.value)) {
+			break
+		}
+	}
+	return reaped
+}
+
+// SetOnEvict replaces the callback called when an entry is removed.
+func (c *LRU[K, V]) SetOnEvict(onEvict EvictCallback[K, V]) {
+	c.onEvict = onEvict
+}
+
+// Rename moves the entry stored under oldKey to newKey, keeping its value,
+// expiry and "recently used"-ness. An existing entry for newKey is replaced
+// and passed to the eviction callback. Returns false if oldKey is not found
+// or has expired, or if it depends on newKey through AddWithDeps, as
+// replacing newKey would remove it as well.
+func (c *LRU[K, V]) Rename(oldKey, newKey K) (ok bool) {
+	ent, ok := c.items[oldKey]
+	if !ok || c.KeyHasExpired(oldKey) {
+		return false
+	}
+	if oldKey == newKey {
+		return true
+	}
+	if c.itemDeps != nil && c.dependsOn(oldKey, newKey) {
+		return false
+	}
+
+	if displaced, ok := c.items[newKey]; ok {
+		c.dropElement(displaced, removeManual)
+	}
+
+	delete(c.items, oldKey)
+	ent.key = newKey
+	c.items[newKey] = ent
+	if expiry, ok := c.itemExpiries[oldKey]; ok {
+		delete(c.itemExpiries, oldKey)
+		c.setExpiry(newKey, expiry)
+	}
+	if n, ok := c.itemReads[oldKey]; ok {
+		delete(c.itemReads, oldKey)
+		c.itemReads[newKey] = n
+	}
+	if t, ok := c.itemWrites[oldKey]; ok {
+		delete(c.itemWrites, oldKey)
+		c.itemWrites[newKey] = t
+	}
+	if v, ok := c.overrides[oldKey]; ok {
+		delete(c.overrides, oldKey)
+		c.overrides[newKey] = v
+	}
+	if c.itemDeps != nil {
+		c.renameDeps(oldKey, newKey)
+	}
+	if c.policy != nil {
+		c.policy.RecordRemove(oldKey)
+		c.policy.RecordInsert(newKey)
+	}
+	return true
+}
+
+// RemoveOldest removes the oldest item from the cache. Like Remove, this
+// does not count as an eviction.
 func (c *LRU[K, V]) RemoveOldest() (key K, value V, ok bool) {
 	if ent, ok := c.getOldest(false); ok {
-		c.removeElement(ent)
-		return ent.key, ent.value, true
+		key, value = ent.key, ent.value
+		c.dropElement(ent, removeManual)
+		return key, value, true
 	}
 
 	return
@@ -153,46 +826,104 @@ func (c *LRU[K, V]) RemoveOldest() (key K, value V, ok bool) {
 // GetOldest returns the oldest entry
 func (c *LRU[K, V]) GetOldest() (key K, value V, ok bool) {
 	if ent, ok := c.getOldest(false); ok {
-		return ent.key, ent.value, true
+		return ent.key, c.clone(ent.value), true
 	}
 
 	return
 }
 
-// Keys returns a slice of the keys in the cache, from oldest to newest.
-func (c *LRU[K, V]) Keys() []K {
-	var next *entry[K, V]
-	keys := make([]K, c.evictList.length())
+// ColdestEntries returns up to n of the oldest entries in the cache, from
+// oldest to newest, without updating their "recently used"-ness.
+// Expired entries are skipped.
+func (c *LRU[K, V]) ColdestEntries(n int) []Entry[K, V] {
+	if n > c.evictList.length() {
+		n = c.evictList.length()
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	entries := make([]Entry[K, V], 0, n)
+	for ent := c.evictList.back(); ent != nil && len(entries) < n; ent = ent.prevEntry() {
+		if c.KeyHasExpired(ent.key) {
+			continue
+		}
+		entries = append(entries, Entry[K, V]{Key: ent.key, Value: ent.value, Expiry: c.itemExpiries[ent.key]})
+	}
+	return entries
+}
+
+// EntryAt returns the entry at the given position among the entries which
+// have not expired, counting from 0 for the most recently used one, without
+// updating its "recently used"-ness. ok is false if index is out of range.
+// The list is walked up to the position, which costs O(index).
+func (c *LRU[K, V]) EntryAt(index int) (entry Entry[K, V], ok bool) {
+	if entries := c.EntriesRange(index, index+1); len(entries) == 1 {
+		return entries[0], true
+	}
+	return
+}
+
+// EntriesRange returns the entries from position start up to but excluding
+// end, counted like EntryAt, from the most to the least recently used. Fewer
+// entries are returned if end is out of range. The cost is O(end).
+func (c *LRU[K, V]) EntriesRange(start, end int) []Entry[K, V] {
+	if start < 0 {
+		start = 0
+	}
+	if end > c.evictList.length() {
+		end = c.evictList.length()
+	}
+	if start >= end {
+		return nil
+	}
+
+	entries := make([]Entry[K, V], 0, end-start)
 	i := 0
-	for ent := c.evictList.back(); ent != nil; {
-		next = ent.prevEntry()
-		if !c.KeyHasExpired(ent.key) {
-			keys[i] = ent.key
-			i++
-		} else {
-			c.removeElement(ent)
+	for ent := c.evictList.front(); ent != nil && i < end; ent = ent.nextEntry() {
+		if c.KeyHasExpired(ent.key) {
+			continue
 		}
-		ent = next
+		if i >= start {
+			entries = append(entries, Entry[K, V]{Key: ent.key, Value: ent.value, Expiry: c.itemExpiries[ent.key]})
+		}
+		i++
+	}
+	return entries
+}
+
+// Stream sends the entries which have not expired to ch, from oldest to
+// newest, without updating their "recently used"-ness, and closes ch when
+// done. Unlike ColdestEntries it does not copy all entries at once, but the
+// cache must not be modified until Stream returns.
+func (c *LRU[K, V]) Stream(ch chan<- Entry[K, V]) {
+	defer close(ch)
+	for ent := c.evictList.back(); ent != nil; ent = ent.prevEntry() {
+		if c.KeyHasExpired(ent.key) {
+			continue
+		}
+		ch <- Entry[K, V]{Key: ent.key, Value: ent.value, Expiry: c.itemExpiries[ent.key]}
+	}
+}
+
+// Keys returns a slice of the keys in the cache, from oldest to newest.
+func (c *LRU[K, V]) Keys() []K {
+	c.RemoveExpired()
+	keys := make([]K, 0, c.evictList.length())
+	for ent := c.evictList.back(); ent != nil; ent = ent.prevEntry() {
+		keys = append(keys, ent.key)
 	}
-	return keys[:i]
+	return keys
 }
 
 // Values returns a slice of the values in the cache, from oldest to newest.
 func (c *LRU[K, V]) Values() []V {
-	var next *entry[K, V]
-	values := make([]V, len(c.items))
-	i := 0
-	for ent := c.evictList.back(); ent != nil; {
-		next = ent.prevEntry()
-		if !c.KeyHasExpired(ent.key) {
-			values[i] = ent.value
-			i++
-		} else {
-			c.removeElement(ent)
-		}
-		ent = next
+	c.RemoveExpired()
+	values := make([]V, 0, c.evictList.length())
+	for ent := c.evictList.back(); ent != nil; ent = ent.prevEntry() {
+		values = append(values, c.clone(ent.value))
 	}
-	return values[:i]
+	return values
 }
 
 // Len returns the physical number of items in the cache.
@@ -206,6 +937,48 @@ func (c *LRU[K, V]) ItemCount() int {
 	return len(c.Keys())
 }
 
+// WouldEvict checks, without modifying the cache, if adding key would evict
+// another entry, that is if key is not in the cache or has expired and the
+// cache is full of entries which have not expired.
+func (c *LRU[K, V]) WouldEvict(key K) bool {
+	if _, ok := c.items[key]; ok && !c.KeyHasExpired(key) {
+		return false
+	}
+	return c.liveLen() >= c.size
+}
+
+// Occupancy returns the fraction of the capacity taken by entries which have
+// not expired, from 0 for an empty cache to 1 for a full one, without
+// modifying the cache. If the capacity is not positive, as Resize allows,
+// no entry fits and it returns 1.
+func (c *LRU[K, V]) Occupancy() float64 {
+	if c.size <= 0 {
+		return 1
+	}
+	return float64(c.liveLen()) / float64(c.size)
+}
+
+// liveLen returns the number of entries which have not expired.
+func (c *LRU[K, V]) liveLen() int {
+	live, _ := c.LenBreakdown()
+	return live
+}
+
+// LenBreakdown returns the number of entries which have not expired and the
+// number of those which have expired but not been removed yet, in a single
+// pass over the entries which expire.
+func (c *LRU[K, V]) LenBreakdown() (live, expired int) {
+	if len(c.itemExpiries) > 0 {
+		now := c.now()
+		for _, expiry := range c.itemExpiries {
+			if expiry.Before(now) {
+				expired++
+			}
+		}
+	}
+	return c.evictList.length() - expired, expired
+}
+
 // Resize changes the cache size.
 func (c *LRU[K, V]) Resize(size int) (evicted int) {
 	diff := c.Len() - size
@@ -213,17 +986,89 @@ func (c *LRU[K, V]) Resize(size int) (evicted int) {
 		diff = 0
 	}
 	for i := 0; i < diff; i++ {
-		c.removeOldest()
+		c.releaseEntry(c.removeOldest())
 	}
 	c.size = size
 	return diff
 }
 
-// removeOldest removes the oldest item from the cache.
-func (c *LRU[K, V]) removeOldest() {
-	if ent, ok := c.getOldest(true); ok {
-		c.removeElement(ent)
+// EvictTo evicts entries as if to make room until at most target entries
+// are left, and returns the number removed. Unlike Resize it does not change
+// the size of the cache.
+func (c *LRU[K, V]) EvictTo(target int) (evicted int) {
+	for c.evictList.length() > target {
+		ent := c.removeOldest()
+		if ent == nil {
+			break
+		}
+		c.releaseEntry(ent)
+		evicted++
+	}
+	return evicted
+}
+
+// ResizeKeeping changes the cache size like Resize, but when shrinking it
+// skips entries for which keep returns true and evicts younger ones instead.
+// If too many entries are kept the cache stays over its size; each
+// subsequent addition then evicts one more of the oldest entries, regardless
+// of keep, until the cache is back to its size.
+func (c *LRU[K, V]) ResizeKeeping(size int, keep func(key K, value V) bool) (evicted int) {
+	var next *entry[K, V]
+	for ent := c.evictList.back(); ent != nil && c.Len() > size; ent = next {
+		next = ent.prevEntry()
+		if c.KeyHasExpired(ent.key) {
+			c.dropElement(ent, removeExpired)
+			evicted++
+			next = c.resume(next)
+		} else if !keep(ent.key, ent.value) {
+			c.dropElement(ent, removeEvicted)
+			evicted++
+			next = c.resume(next)
+		}
+	}
+	c.size = size
+	return evicted
+}
+
+// removeOldest removes the oldest item from the cache
+// and returns the removed list element, if any.
+func (c *LRU[K, V]) removeOldest() *entry[K, V] {
+	ent, ok := c.getOldest(true)
+	if !ok {
+		return nil
+	}
+	if c.policy != nil && !c.KeyHasExpired(ent.key) {
+		ent = c.victim(ent)
+	} else if c.beforeEvict != nil && !c.KeyHasExpired(ent.key) {
+		ent = c.rescue(ent)
+	}
+	if c.KeyHasExpired(ent.key) {
+		if c.observeVictim != nil {
+			c.observeVictim(ent.key, EvictReasonExpired)
+		}
+		c.removeElement(ent, removeExpired)
+	} else {
+		if c.observeVictim != nil {
+			c.observeVictim(ent.key, EvictReasonCapacity)
+		}
+		c.removeElement(ent, removeEvicted)
+	}
+	return ent
+}
+
+// rescue offers the victim, and then the next oldest entries, to the
+// beforeEvict callback, moving rescued entries to the front. It returns the
+// first entry that is not rescued, or that has expired. If every entry is
+// rescued the victim is returned after a full pass, so it is evicted anyway.
+func (c *LRU[K, V]) rescue(victim *entry[K, V]) *entry[K, V] {
+	for n := c.evictList.length(); n > 0; n-- {
+		if c.KeyHasExpired(victim.key) || !c.beforeEvict(victim.key, victim.value) {
+			return victim
+		}
+		c.evictList.moveToFront(victim)
+		victim = c.evictList.back()
 	}
+	return victim
 }
 
 func (c *LRU[K, V]) getOldest(includeExpired bool) (oldest *entry[K, V], ok bool) {
@@ -240,28 +1085,153 @@ func (c *LRU[K, V]) getOldest(includeExpired bool) (oldest *entry[K, V], ok bool
 			return ent, true
 		}
 
-		next = ent.prev
-		c.removeElement(ent)
-		ent = next
+		next = ent.prevEntry()
+		if includeExpired && c.observeVictim != nil {
+			c.observeVictim(ent.key, EvictReasonExpired)
+		}
+		c.dropElement(ent, removeExpired)
+		ent = c.resume(next)
 	}
 
 	return
 }
 
+// EvictReason tells why an entry was chosen to make room in the cache.
+type EvictReason int
+
+const (
+	// EvictReasonCapacity is used for live entries chosen as the least
+	// recently used one, or by the eviction policy.
+	EvictReasonCapacity EvictReason = iota
+	// EvictReasonExpired is used for expired entries, which are
+	// preferred over live ones.
+	EvictReasonExpired
+)
+
+// String returns the name of the reason.
+func (r EvictReason) String() string {
+	switch r {
+	case EvictReasonCapacity:
+		return "capacity"
+	case EvictReasonExpired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+// removeReason tells why an entry is removed from the cache.
+type removeReason int
+
+const (
+	// removeManual is used for entries removed on request.
+	removeManual removeReason = iota
+	// removeEvicted is used for live entries removed to make room.
+	removeEvicted
+	// removeExpired is used for entries removed after they have expired.
+	removeExpired
+)
+
+// dropElement removes a list element from the cache like removeElement,
+// and releases it for reuse. The element must not be used afterwards.
+func (c *LRU[K, V]) dropElement(e *entry[K, V], reason removeReason) {
+	c.removeElement(e, reason)
+	c.releaseEntry(e)
+}
+
+// resume returns the element to continue an iteration from oldest to newest
+// with after an element was removed. If next was removed along with it as a
+// dependent, the iteration restarts from the oldest element.
+func (c *LRU[K, V]) resume(next *entry[K, V]) *entry[K, V] {
+	if next != nil && next.list == nil {
+		return c.evictList.back()
+	}
+	return next
+}
+
+// pooledEntry returns a released list element for reuse, or nil if there is
+// none, in which case pushFrontEntry allocates a new one.
+func (c *LRU[K, V]) pooledEntry() *entry[K, V] {
+	e, _ := c.entryPool.Get().(*entry[K, V])
+	return e
+}
+
+// releaseEntry clears a removed list element, so that its key and value can
+// be garbage collected, and puts it into the pool for reuse. e may be nil.
+func (c *LRU[K, V]) releaseEntry(e *entry[K, V]) {
+	if e == nil {
+		return
+	}
+	var key K
+	var value V
+	e.key, e.value = key, value
+	c.entryPool.Put(e)
+}
+
 // removeElement is used to remove a given list element from the cache
-func (c *LRU[K, V]) removeElement(e *entry[K, V]) {
+func (c *LRU[K, V]) removeElement(e *entry[K, V], reason removeReason) {
 	c.evictList.remove(e)
 	delete(c.items, e.key)
-	delete(c.itemExpiries, e.key)
+	if len(c.itemExpiries) > 0 {
+		delete(c.itemExpiries, e.key)
+	}
+	if len(c.itemReads) > 0 {
+		delete(c.itemReads, e.key)
+	}
+	if len(c.itemWrites) > 0 {
+		delete(c.itemWrites, e.key)
+	}
+	if len(c.overrides) > 0 {
+		delete(c.overrides, e.key)
+	}
+	if c.policy != nil {
+		c.policy.RecordRemove(e.key)
+	}
+	switch reason {
+	case removeManual:
+		c.emit(EventRemove, e.key, e.value)
+	case removeEvicted:
+		c.record(eventEviction)
+		c.emit(EventEvict, e.key, e.value)
+	case removeExpired:
+		c.record(eventExpiration)
+		c.emit(EventExpire, e.key, e.value)
+	}
+	if reason != removeManual {
+		if c.evicted != nil {
+			c.evicted.Add(e.key, struct{}{})
+		}
+		if c.sizer != nil {
+			c.bytesEvicted += c.sizer(e.value)
+		}
+	}
 	if c.onEvict != nil {
 		c.onEvict(e.key, e.value)
 	}
+	if c.itemDeps != nil {
+		c.removeDependents(e.key)
+	}
+}
+
+// WasRecentlyEvicted checks if key belongs to one of the entries most
+// recently removed by eviction or expiry. It always returns false unless
+// the cache was created with WithEvictedRecord.
+func (c *LRU[K, V]) WasRecentlyEvicted(key K) bool {
+	return c.evicted != nil && c.evicted.Contains(key)
+}
+
+// BytesEvicted returns the total size of all values that have been evicted
+// or have expired, as reported by the sizer set with WithSizer.
+func (c *LRU[K, V]) BytesEvicted() int64 {
+	return c.bytesEvicted
 }
 
 // Checks if a given key has expired.
 func (c *LRU[K, V]) KeyHasExpired(key K) (expired bool) {
+	if len(c.itemExpiries) == 0 {
+		return false
+	}
 	expiry, ok := c.itemExpiries[key]
-	return ok && expiry.Before(time.Now())
+	return ok && expiry.Before(c.now())
 }
 
 // Returns the expiry for a given key.
@@ -271,44 +1241,195 @@ func (c *LRU[K, V]) ExpiryForKey(key K) (expiry time.Time) {
 	return c.itemExpiries[key]
 }
 
+// deadlineNow returns the current time to compute expiries from. It never
+// goes backwards, even if the clock does, so that entries added later never
+// get an earlier expiry for the same TTL and cannot expire prematurely.
+// Expiries are checked against the clock itself, so after the clock went
+// backwards entries expire late rather than early.
+func (c *LRU[K, V]) deadlineNow() time.Time {
+	now := c.now()
+	if now.Before(c.lastNow) {
+		return c.lastNow
+	}
+	c.lastNow = now
+	return now
+}
+
+// Sets the expiry for a key, keeping track of the earliest expiry.
+// The max TTL is applied after rounding to the granularity, so that
+// rounding up cannot take an expiry beyond it.
+func (c *LRU[K, V]) setExpiry(key K, expiry time.Time) {
+	if g := c.expiryGranularity; g > 0 {
+		// Truncate drops the monotonic clock reading,
+		// so add the difference to keep it
+		rounded := expiry.Truncate(g)
+		if rounded.Before(expiry) {
+			rounded = rounded.Add(g)
+		}
+		expiry = expiry.Add(rounded.Sub(expiry))
+	}
+	if c.maxTTL > 0 {
+		if latest := c.deadlineNow().Add(c.maxTTL); expiry.After(latest) {
+			expiry = latest
+		}
+	}
+	if c.itemExpiries == nil {
+		c.itemExpiries = make(map[K]time.Time)
+	}
+	c.itemExpiries[key] = expiry
+	if c.minExpiry.IsZero() || expiry.Before(c.minExpiry) {
+		c.minExpiry = expiry
+	}
+}
+
 // Finds the first entry that has expired.
 func (c *LRU[K, V]) findExpired() (entry *entry[K, V], ok bool) {
+	if len(c.itemExpiries) == 0 || c.now().Before(c.minExpiry) {
+		return
+	}
+
+	var minExpiry time.Time
 	for ent := c.evictList.back(); ent != nil; ent = ent.prevEntry() {
 		if c.KeyHasExpired(ent.key) {
 			return ent, true
 		}
+		if expiry, ok := c.itemExpiries[ent.key]; ok && (minExpiry.IsZero() || expiry.Before(minExpiry)) {
+			minExpiry = expiry
+		}
 	}
 
+	// Nothing has expired, so the bound can be raised
+	c.minExpiry = minExpiry
 	return
 }
 
+// RangeExpired calls f for each expired entry, from oldest to newest,
+// without removing it, until f returns false.
+func (c *LRU[K, V]) RangeExpired(f func(key K, value V) bool) {
+	if len(c.itemExpiries) == 0 {
+		return
+	}
+	for ent := c.evictList.back(); ent != nil; ent = ent.prevEntry() {
+		if c.KeyHasExpired(ent.key) && !f(ent.key, ent.value) {
+			return
+		}
+	}
+}
+
+// ExpiredKeys returns the keys of the entries which have expired but not
+// been removed yet, from oldest to newest, without removing them.
+func (c *LRU[K, V]) ExpiredKeys() []K {
+	var keys []K
+	c.RangeExpired(func(key K, _ V) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// TTLPercentiles returns the percentiles ps of the remaining TTLs of the
+// entries which expire and have not expired yet, using the nearest-rank
+// method. Entries which never expire are not counted. Each of ps must be
+// between 0 and 1; otherwise, or if no entry expires, nil is returned.
+func (c *LRU[K, V]) TTLPercentiles(ps ...float64) []time.Duration {
+	for _, p := range ps {
+		if !(p >= 0 && p <= 1) {
+			return nil
+		}
+	}
+
+	now := c.now()
+	ttls := make([]time.Duration, 0, len(c.itemExpiries))
+	for _, expiry := range c.itemExpiries {
+		if !expiry.Before(now) {
+			ttls = append(ttls, expiry.Sub(now))
+		}
+	}
+	if len(ttls) == 0 {
+		return nil
+	}
+	sort.Slice(ttls, func(i, j int) bool { return ttls[i] < ttls[j] })
+
+	res := make([]time.Duration, len(ps))
+	for i, p := range ps {
+		rank := int(math.Ceil(p * float64(len(ttls))))
+		if rank > 0 {
+			rank--
+		}
+		res[i] = ttls[rank]
+	}
+	return res
+}
+
 // Removes all expired entries from the cache.
 func (c *LRU[K, V]) RemoveExpired() (evicted int) {
-	var next *entry[K, V]
+	// Nothing can have expired without entries which expire,
+	// or before the earliest expiry
+	if len(c.itemExpiries) == 0 || c.now().Before(c.minExpiry) {
+		return
+	}
 
+	var next *entry[K, V]
+	var minExpiry time.Time
 	for ent := c.evictList.back(); ent != nil; {
 		next = ent.prevEntry()
 		if c.KeyHasExpired(ent.key) {
-			c.removeElement(ent)
+			c.dropElement(ent, removeExpired)
 			evicted++
+			next = c.resume(next)
+		} else if expiry, ok := c.itemExpiries[ent.key]; ok && (minExpiry.IsZero() || expiry.Before(minExpiry)) {
+			minExpiry = expiry
 		}
 		ent = next
 	}
 
+	// The remaining entries have not expired, so the bound can be raised
+	c.minExpiry = minExpiry
 	return
 }
 
 // Change the expiry for an item in the cache.
-// The expiry of already expired items cannot be changed.
+// The expiry of already expired items cannot be changed, nor can it be set
+// beyond the max TTL with WithRejectOverMaxTTL.
 func (c *LRU[K, V]) ChangeExpiry(key K, expiry time.Time) (ok bool) {
-	if _, ok := c.Peek(key); ok {
-		c.itemExpiries[key] = expiry
-		return true
+	if ent, ok := c.items[key]; ok {
+		if !c.KeyHasExpired(key) {
+			if c.checkExpiry(expiry) != nil {
+				return false
+			}
+			c.setExpiry(key, expiry)
+			return true
+		}
+		c.dropElement(ent, removeExpired)
 	}
 
 	return
 }
 
+// RefreshAllTTL sets the expiry of every entry which has not expired to
+// ttl from now, or makes them never expire if ttl is not positive, and
+// returns the number of entries updated. Expired entries are left to be
+// removed as usual. The TTL of entries added later is not changed. With
+// WithRejectOverMaxTTL, no entry is updated if ttl exceeds the max TTL.
+func (c *LRU[K, V]) RefreshAllTTL(ttl time.Duration) (updated int) {
+	expiry := c.deadlineNow().Add(ttl)
+	if ttl > 0 && c.checkExpiry(expiry) != nil {
+		return 0
+	}
+	for ent := c.evictList.back(); ent != nil; ent = ent.prevEntry() {
+		if c.KeyHasExpired(ent.key) {
+			continue
+		}
+		if ttl > 0 {
+			c.setExpiry(ent.key, expiry)
+		} else {
+			delete(c.itemExpiries, ent.key)
+		}
+		updated++
+	}
+	return updated
+}
+
 func MoveItem[K comparable, V any](key K, dest, src LRUCache[K, V]) (value V, moved bool) {
 	if val, ok := src.Peek(key); ok {
 		if !src.KeyHasExpired(key) {
@@ -4,10 +4,165 @@
 package simplelru
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
 
+type benchValue struct {
+	id   int
+	name string
+}
+
+func benchEntries(n int) ([]string, []*benchValue) {
+	keys := make([]string, n)
+	vals := make([]*benchValue, n)
+	for i := 0; i < n; i++ {
+		keys[i] = "key-" + strconv.Itoa(i)
+		vals[i] = &benchValue{id: i, name: keys[i]}
+	}
+	return keys, vals
+}
+
+// benchBefore holds the results of the benchmarks before the hot paths of
+// Add and Get were optimized (go test -bench . -benchtime 500000x), which
+// reportBefore adds to their output to compare with.
+var benchBefore = map[string]map[string]float64{
+	"BenchmarkLRU_Add":      {"before-ns/op": 200, "before-allocs/op": 1},
+	"BenchmarkLRU_AddEvict": {"before-ns/op": 184, "before-allocs/op": 1},
+	"BenchmarkLRU_AddTTL":   {"before-ns/op": 99000, "before-allocs/op": 1},
+	"BenchmarkLRU_Get":      {"before-ns/op": 20, "before-allocs/op": 0},
+	"BenchmarkLRU_GetTTL":   {"before-ns/op": 188, "before-allocs/op": 0},
+	"BenchmarkLRU_Mixed":    {"before-ns/op": 39, "before-B/op": 4},
+	"BenchmarkLRU_MixedTTL": {"before-ns/op": 9500, "before-B/op": 5},
+}
+
+func reportBefore(b *testing.B) {
+	for unit, n := range benchBefore[b.Name()] {
+		b.ReportMetric(n, unit)
+	}
+}
+
+func benchmarkLRUAdd(b *testing.B, itemTTL time.Duration, onEvict EvictCallback[string, *benchValue]) {
+	keys, vals := benchEntries(4096)
+	l, err := NewLRUWithEvictTTL(1024, onEvict, itemTTL)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Add(keys[i%len(keys)], vals[i%len(vals)])
+	}
+	reportBefore(b)
+}
+
+func benchmarkLRUGet(b *testing.B, itemTTL time.Duration, onEvict EvictCallback[string, *benchValue]) {
+	keys, vals := benchEntries(1024)
+	l, err := NewLRUWithEvictTTL(1024, onEvict, itemTTL)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	for i := range keys {
+		l.Add(keys[i], vals[i])
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Get(keys[i%len(keys)])
+	}
+	reportBefore(b)
+}
+
+func benchmarkLRUMixed(b *testing.B, itemTTL time.Duration, onEvict EvictCallback[string, *benchValue]) {
+	keys, vals := benchEntries(2048)
+	l, err := NewLRUWithEvictTTL(1024, onEvict, itemTTL)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	trace := make([]int, 8192)
+	for i := range trace {
+		trace[i] = r.Intn(len(keys))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := trace[i%len(trace)]
+		if i%4 == 0 {
+			l.Add(keys[k], vals[k])
+		} else {
+			l.Get(keys[k])
+		}
+	}
+	reportBefore(b)
+}
+
+func benchEvict(key string, value *benchValue) {}
+
+func benchmarkLRURemoveExpired(b *testing.B, itemTTL time.Duration) {
+	keys, vals := benchEntries(1 << 16)
+	l, err := NewLRUWithEvictTTL[string, *benchValue](len(keys), nil, itemTTL)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	for i := range keys {
+		l.Add(keys[i], vals[i])
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.RemoveExpired()
+	}
+}
+
+func BenchmarkLRU_Add(b *testing.B)           { benchmarkLRUAdd(b, 0, nil) }
+func BenchmarkLRU_AddTTL(b *testing.B)        { benchmarkLRUAdd(b, time.Hour, nil) }
+func BenchmarkLRU_AddEvict(b *testing.B)      { benchmarkLRUAdd(b, 0, benchEvict) }
+func BenchmarkLRU_AddTTLEvict(b *testing.B)   { benchmarkLRUAdd(b, time.Hour, benchEvict) }
+func BenchmarkLRU_Get(b *testing.B)           { benchmarkLRUGet(b, 0, nil) }
+func BenchmarkLRU_GetTTL(b *testing.B)        { benchmarkLRUGet(b, time.Hour, nil) }
+func BenchmarkLRU_GetEvict(b *testing.B)      { benchmarkLRUGet(b, 0, benchEvict) }
+func BenchmarkLRU_GetTTLEvict(b *testing.B)   { benchmarkLRUGet(b, time.Hour, benchEvict) }
+func BenchmarkLRU_Mixed(b *testing.B)         { benchmarkLRUMixed(b, 0, nil) }
+func BenchmarkLRU_MixedTTL(b *testing.B)      { benchmarkLRUMixed(b, time.Hour, nil) }
+func BenchmarkLRU_MixedEvict(b *testing.B)    { benchmarkLRUMixed(b, 0, benchEvict) }
+func BenchmarkLRU_MixedTTLEvict(b *testing.B) { benchmarkLRUMixed(b, time.Hour, benchEvict) }
+
+// BenchmarkLRU_Churn adds and removes entries without evictions,
+// so that list elements can only be reused through the pool.
+func BenchmarkLRU_Churn(b *testing.B) {
+	keys, vals := benchEntries(2048)
+	l, err := NewLRU[string, *benchValue](len(keys), nil)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	for i := 0; i < len(keys)/2; i++ {
+		l.Add(keys[i], vals[i])
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := i % len(keys)
+		l.Remove(keys[(k+len(keys)/2)%len(keys)])
+		l.Add(keys[k], vals[k])
+	}
+}
+
+func BenchmarkLRU_RemoveExpired(b *testing.B)    { benchmarkLRURemoveExpired(b, 0) }
+func BenchmarkLRU_RemoveExpiredTTL(b *testing.B) { benchmarkLRURemoveExpired(b, time.Hour) }
+
 func TestLRU(t *testing.T) {
 	evictCounter := 0
 	onEvicted := func(k int, v int) {
@@ -240,4 +395,2023 @@ func TestLRU_TTL(t *testing.T) {
 	if l.Len() != 0 {
 		t.Errorf("Cache Len() should be 0, since item should have been removed")
 	}
+}
+// Test that Rename keeps recent-ness and evicts only the displaced entry
+func TestLRU_Rename(t *testing.T) {
+	var evicted []int
+	onEvicted := func(k int, v int) {
+		evicted = append(evicted, k)
+	}
+	l, err := NewLRU(3, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+
+	if !l.Rename(1, 10) {
+		t.Fatalf("1 should have been renamed")
+	}
+	if l.Contains(1) {
+		t.Errorf("1 should no longer be contained")
+	}
+	if v, ok := l.Peek(10); !ok || v != 1 {
+		t.Errorf("10 should be set to 1: %v, %v", v, ok)
+	}
+	if len(evicted) != 0 {
+		t.Errorf("rename should not have evicted anything: %v", evicted)
+	}
+	if k, _, _ := l.GetOldest(); k != 10 {
+		t.Errorf("10 should have kept the position of 1: %v", k)
+	}
+
+	// Rename onto an existing key
+	if !l.Rename(10, 3) {
+		t.Fatalf("10 should have been renamed")
+	}
+	if len(evicted) != 1 || evicted[0] != 3 {
+		t.Errorf("only the displaced entry 3 should have been evicted: %v", evicted)
+	}
+	if v, ok := l.Peek(3); !ok || v != 1 {
+		t.Errorf("3 should be set to 1: %v, %v", v, ok)
+	}
+	if l.Len() != 2 {
+		t.Errorf("bad len: %v", l.Len())
+	}
+	if k, _, _ := l.GetOldest(); k != 3 {
+		t.Errorf("3 should have kept the position of 10: %v", k)
+	}
+
+	if l.Rename(42, 43) {
+		t.Errorf("missing key should not be renamed")
+	}
+}
+
+// Test that Rename keeps the expiry and refuses expired keys
+func TestLRU_RenameExpiry(t *testing.T) {
+	l, err := NewLRU[int, int](4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expiry := time.Now().Add(time.Hour)
+	l.AddWithExp(1, 1, expiry)
+	l.AddWithExp(2, 2, time.Now().Add(-time.Second))
+
+	if !l.Rename(1, 10) {
+		t.Fatalf("1 should have been renamed")
+	}
+	if !l.ExpiryForKey(10).Equal(expiry) {
+		t.Errorf("expiry should have been kept: %v", l.ExpiryForKey(10))
+	}
+	if !l.ExpiryForKey(1).IsZero() {
+		t.Errorf("old key should not have an expiry: %v", l.ExpiryForKey(1))
+	}
+
+	if l.Rename(2, 20) {
+		t.Errorf("expired key should not be renamed")
+	}
+}
+
+// Test that Add works when every entry in the cache has expired
+func TestLRU_AddAllExpired(t *testing.T) {
+	l, err := NewLRU[int, int](2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	past := time.Now().Add(-time.Second)
+	l.AddWithExp(1, 1, past)
+	l.AddWithExp(2, 2, past)
+	l.Add(3, 3)
+
+	if l.Len() != 1 {
+		t.Errorf("bad len: %v", l.Len())
+	}
+	if v, ok := l.Get(3); !ok || v != 3 {
+		t.Errorf("3 should be set to 3: %v, %v", v, ok)
+	}
+}
+
+// Test that expired entries are evicted before older live ones
+func TestLRU_EvictExpiredFirst(t *testing.T) {
+	l, err := NewLRUWithEvictTTL[int, int](2, nil, time.Hour)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.AddWithExp(2, 2, time.Now().Add(time.Millisecond*20))
+	l.Add(3, 3)
+	if l.Contains(1) || !l.Contains(2) {
+		t.Errorf("1 should have been evicted as nothing had expired")
+	}
+
+	l.Add(4, 4)
+	if l.Contains(2) {
+		t.Errorf("2 should have been evicted")
+	}
+
+	l.AddWithExp(5, 5, time.Now().Add(time.Millisecond*20))
+	time.Sleep(time.Millisecond * 30)
+	l.Add(6, 6)
+	if !l.Contains(4) || l.Contains(5) {
+		t.Errorf("expired 5 should have been evicted before 4")
+	}
+}
+
+// Test that ColdestEntries returns the oldest live entries without updating recent-ness
+func TestLRU_ColdestEntries(t *testing.T) {
+	l, err := NewLRU[int, int](8, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expiry := time.Now().Add(time.Hour)
+	l.AddWithExp(1, 10, expiry)
+	l.AddWithExp(2, 20, time.Now().Add(-time.Second))
+	l.Add(3, 30)
+	l.Add(4, 40)
+	l.Add(5, 50)
+
+	entries := l.ColdestEntries(3)
+	expected := []Entry[int, int]{
+		{Key: 1, Value: 10, Expiry: expiry},
+		{Key: 3, Value: 30},
+		{Key: 4, Value: 40},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("bad entries: %v", entries)
+	}
+	for i := range expected {
+		if entries[i].Key != expected[i].Key || entries[i].Value != expected[i].Value || !entries[i].Expiry.Equal(expected[i].Expiry) {
+			t.Errorf("bad entry %d: %v", i, entries[i])
+		}
+	}
+
+	if k, _, _ := l.GetOldest(); k != 1 {
+		t.Errorf("ColdestEntries should not have updated recent-ness: %v", k)
+	}
+	if l.Len() != 5 {
+		t.Errorf("ColdestEntries should not have removed anything: %v", l.Len())
+	}
+	if n := len(l.ColdestEntries(100)); n != 4 {
+		t.Errorf("should have returned all 4 live entries: %v", n)
+	}
+	if entries := l.ColdestEntries(0); entries != nil {
+		t.Errorf("should have returned nothing: %v", entries)
+	}
+}
+
+// Test that RemoveOldest counts as a removal rather than an eviction
+func TestLRU_RemoveOldestNotEvicted(t *testing.T) {
+	l, err := NewLRUWithEvictTTL(2, nil, 0, WithEvictedRecord[int, int](2))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var events []Event[int, int]
+	l.SetOnEvent(func(ev Event[int, int]) {
+		events = append(events, ev)
+	})
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	if k, _, ok := l.RemoveOldest(); !ok || k != 1 {
+		t.Fatalf("bad oldest: %v, %v", k, ok)
+	}
+	if n := l.Stats().Evictions; n != 0 {
+		t.Errorf("should not count as an eviction: %v", n)
+	}
+	if l.WasRecentlyEvicted(1) {
+		t.Errorf("1 should not have been recorded as evicted")
+	}
+	if ev := events[len(events)-1]; ev.Type != EventRemove || ev.Key != 1 {
+		t.Errorf("bad event: %+v", ev)
+	}
+}
+
+func TestLRU_WasRecentlyEvicted(t *testing.T) {
+	l, err := NewLRUWithEvictTTL(2, nil, 0, WithEvictedRecord[int, int](2))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	if !l.WasRecentlyEvicted(1) {
+		t.Errorf("1 should have been recorded as evicted")
+	}
+	if l.WasRecentlyEvicted(2) || l.WasRecentlyEvicted(42) {
+		t.Errorf("2 and 42 should not have been recorded as evicted")
+	}
+
+	l.AddWithExp(4, 4, time.Now().Add(-time.Second))
+	l.RemoveExpired()
+	if !l.WasRecentlyEvicted(4) {
+		t.Errorf("4 should have been recorded as expired")
+	}
+
+	// The record is bounded
+	l.Add(5, 5)
+	if !l.WasRecentlyEvicted(2) || l.WasRecentlyEvicted(1) {
+		t.Errorf("2 should have replaced 1 in the record")
+	}
+
+	l.Remove(5)
+	if l.WasRecentlyEvicted(5) {
+		t.Errorf("removed keys should not be recorded")
+	}
+
+	if _, err := NewLRUWithEvictTTL(2, nil, 0, WithEvictedRecord[int, int](0)); err == nil {
+		t.Errorf("should have failed for record size 0")
+	}
+
+	l, err = NewLRU[int, int](1, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+	if l.WasRecentlyEvicted(1) {
+		t.Errorf("should not record without WithEvictedRecord")
+	}
+}
+
+// Test that RangeExpired only visits expired entries and keeps them
+func TestLRU_RangeExpired(t *testing.T) {
+	l, err := NewLRU[int, int](8, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	past := time.Now().Add(-time.Second)
+	l.AddWithExp(1, 1, past)
+	l.Add(2, 2)
+	l.AddWithExp(3, 3, past)
+	l.AddWithExp(4, 4, time.Now().Add(time.Hour))
+	l.AddWithExp(5, 5, past)
+
+	var visited []int
+	l.RangeExpired(func(k, v int) bool {
+		visited = append(visited, k)
+		return true
+	})
+	if len(visited) != 3 || visited[0] != 1 || visited[1] != 3 || visited[2] != 5 {
+		t.Errorf("should have visited the expired entries: %v", visited)
+	}
+	if l.Len() != 5 {
+		t.Errorf("expired entries should still be in the cache: %v", l.Len())
+	}
+
+	visited = nil
+	l.RangeExpired(func(k, v int) bool {
+		visited = append(visited, k)
+		return false
+	})
+	if len(visited) != 1 || visited[0] != 1 {
+		t.Errorf("should have stopped after the first entry: %v", visited)
+	}
+
+	if n := l.RemoveExpired(); n != 3 {
+		t.Errorf("3 entries should have been removed: %v", n)
+	}
+}
+
+// Test that PeekPhysical and ContainsPhysical ignore expiry
+func TestLRU_PeekPhysical(t *testing.T) {
+	l, err := NewLRU[int, int](2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.AddWithExp(1, 1, time.Now().Add(-time.Second))
+	l.Add(2, 2)
+
+	if v, ok := l.PeekPhysical(1); !ok || v != 1 {
+		t.Errorf("1 should be physically set to 1: %v, %v", v, ok)
+	}
+	if !l.ContainsPhysical(1) {
+		t.Errorf("1 should be physically contained")
+	}
+	if l.Len() != 2 {
+		t.Errorf("nothing should have been removed: %v", l.Len())
+	}
+	if k, _, _ := l.GetOldest(); k != 2 {
+		t.Errorf("expired 1 should be skipped as oldest: %v", k)
+	}
+	if l.ContainsPhysical(1) {
+		t.Errorf("1 should have been removed by GetOldest")
+	}
+
+	l.AddWithExp(3, 3, time.Now().Add(-time.Second))
+	if _, ok := l.Peek(3); ok {
+		t.Errorf("Peek should not return expired 3")
+	}
+	l.AddWithExp(3, 3, time.Now().Add(-time.Second))
+	if l.Contains(3) {
+		t.Errorf("Contains should not report expired 3")
+	}
+	if _, ok := l.PeekPhysical(42); ok || l.ContainsPhysical(42) {
+		t.Errorf("42 should not be contained")
+	}
+}
+
+// Test that ResizeKeeping evicts younger entries in place of kept ones
+func TestLRU_ResizeKeeping(t *testing.T) {
+	var evicted []int
+	l, err := NewLRU(5, func(k int, v int) {
+		evicted = append(evicted, k)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 1; i <= 5; i++ {
+		l.Add(i, i)
+	}
+	keep := func(k int, v int) bool {
+		return k == 1 || k == 2
+	}
+
+	if n := l.ResizeKeeping(3, keep); n != 2 {
+		t.Errorf("2 entries should have been evicted: %v", n)
+	}
+	if len(evicted) != 2 || evicted[0] != 3 || evicted[1] != 4 {
+		t.Errorf("3 and 4 should have been evicted: %v", evicted)
+	}
+	keys := l.Keys()
+	if len(keys) != 3 || keys[0] != 1 || keys[1] != 2 || keys[2] != 5 {
+		t.Errorf("bad keys: %v", keys)
+	}
+
+	// Keeping everything leaves the cache over its size
+	if n := l.ResizeKeeping(1, func(int, int) bool { return true }); n != 0 {
+		t.Errorf("nothing should have been evicted: %v", n)
+	}
+	if l.Len() != 3 {
+		t.Errorf("bad len: %v", l.Len())
+	}
+
+	// Additions shrink it back to its size
+	l.Add(6, 6)
+	if l.Len() != 2 {
+		t.Errorf("bad len: %v", l.Keys())
+	}
+	l.Add(7, 7)
+	if l.Len() != 1 || !l.Contains(7) {
+		t.Errorf("only 7 should be left: %v", l.Keys())
+	}
+}
+
+// Test that BytesEvicted sums the sizes of evicted and expired values
+func TestLRU_BytesEvicted(t *testing.T) {
+	l, err := NewLRUWithEvictTTL(2, nil, 0, WithSizer[int, string](func(v string) int64 {
+		return int64(len(v))
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, "a")
+	l.Add(2, "bb")
+	l.Add(3, "cccc")
+	if n := l.BytesEvicted(); n != 1 {
+		t.Errorf("bad bytes evicted: %v", n)
+	}
+
+	l.AddWithExp(4, "dddddddd", time.Now().Add(-time.Second))
+	l.RemoveExpired()
+	if n := l.BytesEvicted(); n != 11 {
+		t.Errorf("bad bytes evicted: %v", n)
+	}
+
+	// Manual removals are not counted
+	l.Remove(3)
+	l.Purge()
+	if n := l.BytesEvicted(); n != 11 {
+		t.Errorf("bad bytes evicted: %v", n)
+	}
+}
+
+// Test that PurgeAndCollect returns the live values without evicting them
+func TestLRU_PurgeAndCollect(t *testing.T) {
+	evictCounter := 0
+	l, err := NewLRU(4, func(k int, v int) {
+		evictCounter++
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 10)
+	l.AddWithExp(2, 20, time.Now().Add(-time.Second))
+	l.Add(3, 30)
+	l.Get(1)
+
+	values := l.PurgeAndCollect()
+	if len(values) != 2 || values[0] != 30 || values[1] != 10 {
+		t.Errorf("bad values: %v", values)
+	}
+	if evictCounter != 0 {
+		t.Errorf("onEvicted should not have been called: %v", evictCounter)
+	}
+	if l.Len() != 0 {
+		t.Errorf("bad len: %v", l.Len())
+	}
+
+	l.Add(4, 40)
+	if v, ok := l.Get(4); !ok || v != 40 {
+		t.Errorf("cache should be usable after purging: %v, %v", v, ok)
+	}
+}
+
+// Test that WithBeforeEvict can rescue entries from eviction
+func TestLRU_BeforeEvict(t *testing.T) {
+	var evicted []int
+	rescued := map[int]bool{1: true}
+	l, err := NewLRUWithEvictTTL(3, func(k int, v int) {
+		evicted = append(evicted, k)
+	}, 0, WithBeforeEvict(func(k int, v int) bool {
+		return rescued[k]
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.Add(4, 4)
+	if len(evicted) != 1 || evicted[0] != 2 {
+		t.Errorf("2 should have been evicted in place of 1: %v", evicted)
+	}
+	keys := l.Keys()
+	if len(keys) != 3 || keys[0] != 3 || keys[1] != 1 || keys[2] != 4 {
+		t.Errorf("rescued 1 should have been moved to the front: %v", keys)
+	}
+
+	// When everything is rescued the oldest entry is evicted
+	rescued = map[int]bool{1: true, 3: true, 4: true}
+	l.Add(5, 5)
+	if len(evicted) != 2 || evicted[1] != 3 {
+		t.Errorf("3 should have been evicted after a full pass: %v", evicted)
+	}
+	if l.Len() != 3 {
+		t.Errorf("bad len: %v", l.Len())
+	}
+}
+
+func TestLRU_Stats(t *testing.T) {
+	l, err := NewLRU[int, int](2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.Get(2)
+	l.Get(1)
+	l.AddWithExp(4, 4, time.Now().Add(-time.Second))
+	l.Get(4)
+
+	stats := l.Stats()
+	expected := Stats{Hits: 1, Misses: 2, Evictions: 2, Expirations: 1, ExpiredReads: 1}
+	if stats != expected {
+		t.Errorf("bad stats: %+v", stats)
+	}
+}
+
+// Test that Peek and Contains only update their own counters
+func TestLRU_PeekContainsStats(t *testing.T) {
+	l, err := NewLRU[int, int](2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Peek(1)
+	l.Peek(1)
+	l.Peek(2)
+	if stats := l.Stats(); stats != (Stats{PeekHits: 2, PeekMisses: 1}) {
+		t.Errorf("bad stats after Peek: %+v", stats)
+	}
+
+	l.Contains(1)
+	l.Contains(2)
+	l.Contains(3)
+	if stats := l.Stats(); stats != (Stats{PeekHits: 2, PeekMisses: 1, ContainsHits: 1, ContainsMisses: 2}) {
+		t.Errorf("bad stats after Contains: %+v", stats)
+	}
+
+	l.Get(1)
+	if stats := l.Stats(); stats.Hits != 1 || stats.PeekHits != 2 || stats.ContainsHits != 1 {
+		t.Errorf("bad stats after Get: %+v", stats)
+	}
+}
+
+// Test that WithKeepExpiredOnGet leaves expired entries in place
+func TestLRU_KeepExpiredOnGet(t *testing.T) {
+	l, err := NewLRUWithEvictTTL(2, nil, 0, WithKeepExpiredOnGet[int, int]())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.AddWithExp(1, 1, time.Now().Add(-time.Second))
+	if _, ok := l.Get(1); ok {
+		t.Errorf("expired 1 should be a miss")
+	}
+	if _, ok := l.Get(1); ok {
+		t.Errorf("expired 1 should be a miss")
+	}
+	if l.Len() != 1 || !l.ContainsPhysical(1) {
+		t.Errorf("expired 1 should have been left in place")
+	}
+
+	stats := l.Stats()
+	if stats.ExpiredReads != 2 || stats.Misses != 2 || stats.Expirations != 0 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+
+	if n := l.RemoveExpired(); n != 1 {
+		t.Errorf("expired 1 should have been reaped: %v", n)
+	}
+}
+
+// Test that WithExpiryGranularity rounds expiries up
+func TestLRU_ExpiryGranularity(t *testing.T) {
+	l, err := NewLRUWithEvictTTL(100, nil, time.Minute, WithExpiryGranularity[int, int](time.Second))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	base := time.Now().Truncate(time.Second).Add(time.Hour)
+	l.AddWithExp(1, 1, base)
+	l.AddWithExp(2, 2, base.Add(time.Millisecond))
+	if !l.ExpiryForKey(1).Equal(base) {
+		t.Errorf("exact expiry should be kept: %v", l.ExpiryForKey(1))
+	}
+	if !l.ExpiryForKey(2).Equal(base.Add(time.Second)) {
+		t.Errorf("expiry should have been rounded up: %v", l.ExpiryForKey(2))
+	}
+
+	// Entries added in quick succession share few expiries
+	for i := 3; i < 100; i++ {
+		l.Add(i, i)
+	}
+	expiries := make(map[time.Time]bool)
+	for i := 3; i < 100; i++ {
+		// compare wall clock times, expiries keep the monotonic reading
+		expiry := l.ExpiryForKey(i).Round(0)
+		if expiry.Truncate(time.Second) != expiry {
+			t.Fatalf("expiry should be a whole second: %v", expiry)
+		}
+		expiries[expiry] = true
+	}
+	if len(expiries) > 2 {
+		t.Errorf("expiries should have been bucketed: %v", len(expiries))
+	}
+
+	if _, err := NewLRUWithEvictTTL(1, nil, 0, WithExpiryGranularity[int, int](-time.Second)); err == nil {
+		t.Errorf("should have failed for a negative granularity")
+	}
+}
+
+// Test that WithRejectZeroKey rejects zero keys
+func TestLRU_RejectZeroKey(t *testing.T) {
+	l, err := NewLRUWithEvictTTL(2, nil, 0, WithRejectZeroKey[string, int]())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, err := l.AddChecked("", 1, time.Time{}); err != ErrZeroKey {
+		t.Errorf("zero key should have been rejected: %v", err)
+	}
+	l.Add("", 1)
+	if l.Len() != 0 {
+		t.Errorf("zero key should not have been added")
+	}
+	if _, err := l.AddChecked("a", 1, time.Time{}); err != nil {
+		t.Errorf("non-zero key should have been accepted: %v", err)
+	}
+	if v, ok := l.Get("a"); !ok || v != 1 {
+		t.Errorf("a should be set to 1: %v, %v", v, ok)
+	}
+
+	// Zero keys are accepted by default
+	l, err = NewLRU[string, int](2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := l.AddChecked("", 1, time.Time{}); err != nil {
+		t.Errorf("zero key should have been accepted: %v", err)
+	}
+	if !l.Contains("") {
+		t.Errorf("zero key should have been added")
+	}
+}
+
+func TestLRU_WouldEvict(t *testing.T) {
+	l, err := NewLRU[int, int](2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	if l.WouldEvict(2) {
+		t.Errorf("adding to a cache which is not full should not evict")
+	}
+
+	l.Add(2, 2)
+	if l.WouldEvict(1) {
+		t.Errorf("adding a present key should not evict")
+	}
+	if !l.WouldEvict(3) {
+		t.Errorf("adding an absent key to a full cache should evict")
+	}
+
+	l.Remove(2)
+	l.AddWithExp(2, 2, time.Now().Add(-time.Second))
+	if l.WouldEvict(3) {
+		t.Errorf("adding to a cache holding an expired entry should not evict")
+	}
+	if l.Len() != 2 {
+		t.Errorf("WouldEvict should not have removed anything: %v", l.Len())
+	}
+}
+
+// Test that entries added with AddWithMaxReads are removed after their reads
+func TestLRU_AddWithMaxReads(t *testing.T) {
+	var evicted []int
+	l, err := NewLRU(4, func(k int, v int) {
+		evicted = append(evicted, k)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.AddWithMaxReads(1, 1, 3)
+	l.AddWithMaxReads(2, 2, 0)
+
+	for i := 0; i < 3; i++ {
+		if _, ok := l.Peek(1); !ok || !l.Contains(1) {
+			t.Fatalf("Peek and Contains should not consume reads")
+		}
+		if v, ok := l.Get(1); !ok || v != 1 {
+			t.Fatalf("read %d of 1 should have succeeded: %v, %v", i, v, ok)
+		}
+	}
+	if _, ok := l.Get(1); ok {
+		t.Errorf("1 should have been removed after 3 reads")
+	}
+	if len(evicted) != 1 || evicted[0] != 1 {
+		t.Errorf("removing 1 should have called onEvicted: %v", evicted)
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, ok := l.Get(2); !ok {
+			t.Fatalf("2 should have unlimited reads")
+		}
+	}
+
+	// Renamed entries keep their reads
+	l.AddWithMaxReads(3, 3, 1)
+	l.Rename(3, 4)
+	l.Get(4)
+	if l.Contains(4) {
+		t.Errorf("4 should have been removed after 1 read")
+	}
+
+	// Updating with Add removes the limit of the replaced value
+	l.AddWithMaxReads(5, 5, 1)
+	l.Add(5, 50)
+	for i := 0; i < 3; i++ {
+		if v, ok := l.Get(5); !ok || v != 50 {
+			t.Fatalf("updated 5 should have unlimited reads: %v, %v", v, ok)
+		}
+	}
+}
+
+func TestLRU_TTLPercentiles(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(200, nil, 0, WithClock[int, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if ps := l.TTLPercentiles(0.5); ps != nil {
+		t.Errorf("should be nil without expiring entries: %v", ps)
+	}
+
+	// Remaining TTLs of 1s to 100s, one entry which has expired
+	// and one which never expires
+	for i := 1; i <= 100; i++ {
+		l.AddWithExp(i, i, clock.Now().Add(time.Duration(i)*time.Second))
+	}
+	l.AddWithExp(0, 0, clock.Now().Add(-time.Second))
+	l.Add(-1, -1)
+
+	ps := l.TTLPercentiles(0, 0.5, 0.9, 0.99, 1)
+	expected := []time.Duration{1, 50, 90, 99, 100}
+	if len(ps) != len(expected) {
+		t.Fatalf("bad percentiles: %v", ps)
+	}
+	for i := range expected {
+		if ps[i] != expected[i]*time.Second {
+			t.Errorf("bad percentile %d: %v != %v", i, ps[i], expected[i]*time.Second)
+		}
+	}
+
+	clock.Advance(time.Second * 50)
+	if ps := l.TTLPercentiles(0.5); len(ps) != 1 || ps[0] != time.Second*25 {
+		t.Errorf("bad median after 50s: %v", ps)
+	}
+
+	for _, p := range []float64{-0.1, 1.1, math.NaN()} {
+		if ps := l.TTLPercentiles(0.5, p); ps != nil {
+			t.Errorf("should be nil for %v: %v", p, ps)
+		}
+	}
+}
+
+func TestLRU_RefreshAllTTL(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(8, nil, time.Minute, WithClock[int, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.AddWithExp(2, 2, clock.Now().Add(time.Second))
+	l.AddWithExp(3, 3, clock.Now().Add(time.Hour))
+	clock.Advance(time.Second * 2)
+
+	if n := l.RefreshAllTTL(time.Hour * 2); n != 2 {
+		t.Errorf("bad updated count: %v", n)
+	}
+	expiry := clock.Now().Add(time.Hour * 2)
+	for _, k := range []int{1, 3} {
+		if !l.ExpiryForKey(k).Equal(expiry) {
+			t.Errorf("bad expiry for %v: %v", k, l.ExpiryForKey(k))
+		}
+	}
+	if !l.KeyHasExpired(2) || l.Len() != 3 {
+		t.Errorf("expired 2 should have been left in place")
+	}
+
+	if n := l.RefreshAllTTL(0); n != 2 {
+		t.Errorf("bad updated count: %v", n)
+	}
+	clock.Advance(time.Hour * 3)
+	if !l.ExpiryForKey(1).IsZero() || !l.Contains(1) || !l.Contains(3) {
+		t.Errorf("1 and 3 should never expire")
+	}
+
+	// The TTL of new entries is unchanged
+	l.Add(4, 4)
+	if !l.ExpiryForKey(4).Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("bad expiry for 4: %v", l.ExpiryForKey(4))
+	}
+}
+
+func TestLRU_Lookup(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(4, nil, 0, WithClock[int, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.AddWithExp(1, 10, clock.Now().Add(time.Second))
+	l.AddWithExp(2, 20, clock.Now().Add(time.Hour))
+	clock.Advance(time.Second * 2)
+
+	if r := l.Lookup(2); r.State != LookupHit || r.Value != 20 || r.Key != 2 {
+		t.Errorf("bad result for live 2: %+v", r)
+	}
+	if r := l.Lookup(1); r.State != LookupExpired || r.Value != 10 {
+		t.Errorf("bad result for expired 1: %+v", r)
+	}
+	if l.Len() != 1 {
+		t.Errorf("expired 1 should have been removed")
+	}
+	if r := l.Lookup(1); r.State != LookupMiss || r.Value != 0 {
+		t.Errorf("bad result for removed 1: %+v", r)
+	}
+	if r := l.Lookup(3); r.State != LookupMiss {
+		t.Errorf("bad result for absent 3: %+v", r)
+	}
+
+	if stats := l.Stats(); stats.Hits != 1 || stats.Misses != 3 || stats.ExpiredReads != 1 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+}
+
+func TestLRU_WriteThrottle(t *testing.T) {
+	clock := newFakeClock()
+	var replaced []int
+	l, err := NewLRUWithEvictTTL(4, func(k, v int) { replaced = append(replaced, v) }, 0,
+		WithClock[int, int](clock.Now), WithWriteThrottle[int, int](time.Second))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	for i := 10; i < 15; i++ {
+		l.Add(1, i)
+		clock.Advance(time.Millisecond * 100)
+	}
+	if v, _ := l.Peek(1); v != 14 {
+		t.Errorf("value should have been updated: %v", v)
+	}
+	if len(replaced) != 0 {
+		t.Errorf("coalesced writes should not call the callback: %v", replaced)
+	}
+	if k, _, _ := l.GetOldest(); k != 1 {
+		t.Errorf("coalesced writes should not move 1 to the front: %v", l.Keys())
+	}
+
+	// The first write after the interval has side effects again
+	clock.Advance(time.Millisecond * 600)
+	l.Add(1, 20)
+	l.Add(1, 21)
+	if len(replaced) != 1 || replaced[0] != 14 {
+		t.Errorf("only the first write should call the callback: %v", replaced)
+	}
+	if k, _, _ := l.GetOldest(); k != 2 {
+		t.Errorf("1 should have been moved to the front: %v", l.Keys())
+	}
+
+	// Keys added again after removal are not throttled
+	l.Remove(1)
+	replaced = nil
+	l.Add(1, 30)
+	if v, _ := l.Peek(1); v != 30 || len(replaced) != 0 {
+		t.Errorf("bad value after re-adding: %v", v)
+	}
+}
+
+func TestLRU_Stream(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(128, nil, 0, WithClock[int, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		l.AddWithExp(i, i*2, clock.Now().Add(time.Duration(i)*time.Second))
+	}
+	l.Get(50)
+	clock.Advance(time.Millisecond * 9500)
+
+	ch := make(chan Entry[int, int])
+	go l.Stream(ch)
+
+	var keys []int
+	for e := range ch {
+		if e.Value != e.Key*2 || !e.Expiry.Equal(l.ExpiryForKey(e.Key)) {
+			t.Errorf("bad entry: %+v", e)
+		}
+		keys = append(keys, e.Key)
+	}
+	if !reflect.DeepEqual(keys, l.Keys()) {
+		t.Errorf("entries should be streamed from oldest to newest:\n%v\n%v", keys, l.Keys())
+	}
+	if len(keys) != 90 || keys[0] != 10 || keys[89] != 50 {
+		t.Errorf("bad keys: %v", keys)
+	}
+}
+
+func TestNewLRUWithEntries(t *testing.T) {
+	now := time.Now()
+	entries := []Entry[int, int]{
+		{Key: 1, Value: 1},
+		{Key: 2, Value: 2, Expiry: now.Add(time.Hour)},
+		{Key: 3, Value: 3, Expiry: now.Add(-time.Hour)},
+		{Key: 4, Value: 4},
+	}
+
+	var evicted int
+	onEvict := func(k, v int) { evicted++ }
+	l, err := NewLRUWithEntries(4, onEvict, entries)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(l.Keys(), []int{1, 2, 4}) {
+		t.Errorf("bad keys: %v", l.Keys())
+	}
+	if !l.ExpiryForKey(2).Equal(entries[1].Expiry) {
+		t.Errorf("bad expiry for 2: %v", l.ExpiryForKey(2))
+	}
+
+	// Only the newest live entries are kept
+	l, err = NewLRUWithEntries(2, onEvict, entries)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(l.Keys(), []int{2, 4}) {
+		t.Errorf("bad keys: %v", l.Keys())
+	}
+	if evicted != 0 {
+		t.Errorf("dropped entries should not be passed to onEvict: %v", evicted)
+	}
+
+	if _, err := NewLRUWithEntries(0, onEvict, entries); err == nil {
+		t.Errorf("should have failed for size 0")
+	}
+}
+
+func TestLRU_VictimObserver(t *testing.T) {
+	clock := newFakeClock()
+	var victims []string
+	observe := func(k int, reason EvictReason) {
+		victims = append(victims, fmt.Sprintf("%d %v", k, reason))
+	}
+	l, err := NewLRUWithEvictTTL(3, nil, time.Minute,
+		WithClock[int, int](clock.Now), WithVictimObserver[int, int](observe))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.Remove(3)
+	l.Add(3, 3)
+	if len(victims) != 0 {
+		t.Errorf("observer should only be called on eviction: %v", victims)
+	}
+
+	l.Add(4, 4)
+	if !reflect.DeepEqual(victims, []string{"1 capacity"}) {
+		t.Errorf("bad victims: %v", victims)
+	}
+
+	// The expired 3 is preferred over the older 2
+	l.ChangeExpiry(3, clock.Now().Add(time.Second))
+	clock.Advance(time.Second * 2)
+	l.Add(5, 5)
+	if !reflect.DeepEqual(victims, []string{"1 capacity", "3 expired"}) {
+		t.Errorf("bad victims: %v", victims)
+	}
+}
+
+// Test that expired entries dropped while looking for the oldest one are
+// observed when the cache has no TTL of its own
+func TestLRU_VictimObserverExpiredTail(t *testing.T) {
+	clock := newFakeClock()
+	var victims []string
+	observe := func(k int, reason EvictReason) {
+		victims = append(victims, fmt.Sprintf("%d %v", k, reason))
+	}
+	l, err := NewLRUWithEvictTTL(3, nil, 0,
+		WithClock[int, int](clock.Now), WithVictimObserver[int, int](observe))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		l.AddWithExp(i, i, clock.Now().Add(time.Second))
+	}
+	clock.Advance(time.Second * 2)
+	l.Add(4, 4)
+	if !reflect.DeepEqual(victims, []string{"1 expired", "2 expired", "3 expired"}) {
+		t.Errorf("bad victims: %v", victims)
+	}
+	if l.Len() != 1 {
+		t.Errorf("bad len: %v", l.Len())
+	}
+}
+
+func TestLRU_ClockBackwards(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(3, nil, time.Second*10, WithClock[int, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	start := clock.Now()
+
+	l.Add(1, 1)
+	clock.Advance(-time.Second * 30)
+	l.Add(2, 2)
+	if l.ExpiryForKey(2).Before(l.ExpiryForKey(1)) {
+		t.Errorf("2 should not expire before 1: %v < %v", l.ExpiryForKey(2), l.ExpiryForKey(1))
+	}
+
+	// Nothing expires before its deadline from the latest time seen
+	clock.Advance(time.Second * 15)
+	l.Add(3, 3)
+	l.Add(4, 4)
+	if n := l.RemoveExpired(); n != 0 {
+		t.Errorf("nothing should have expired: %v", n)
+	}
+	if !reflect.DeepEqual(l.Keys(), []int{2, 3, 4}) {
+		t.Errorf("the oldest entry should have been evicted: %v", l.Keys())
+	}
+
+	clock.now = start.Add(time.Second * 11)
+	if n := l.RemoveExpired(); n != 3 || l.Len() != 0 {
+		t.Errorf("all entries should have expired: %v", n)
+	}
+}
+
+func TestLRU_ExpiryGranularityMonotonic(t *testing.T) {
+	l, err := NewLRUWithEvictTTL(3, nil, time.Hour, WithExpiryGranularity[int, int](time.Second))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+
+	// The rounded expiry keeps the monotonic clock reading of time.Now
+	expiry := l.ExpiryForKey(1)
+	if expiry.Round(0) == expiry {
+		t.Errorf("expiry should have a monotonic clock reading: %v", expiry)
+	}
+	if wall := expiry.Round(0); wall.Truncate(time.Second) != wall {
+		t.Errorf("expiry should be rounded to the second: %v", expiry)
+	}
+}
+
+func TestLRU_LenBreakdown(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(8, nil, 0, WithClock[int, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if live, expired := l.LenBreakdown(); live != 0 || expired != 0 {
+		t.Errorf("bad breakdown of empty cache: %v, %v", live, expired)
+	}
+
+	l.Add(1, 1)
+	l.AddWithExp(2, 2, clock.Now().Add(time.Second))
+	l.AddWithExp(3, 3, clock.Now().Add(time.Second))
+	l.AddWithExp(4, 4, clock.Now().Add(time.Hour))
+	clock.Advance(time.Second * 2)
+
+	if live, expired := l.LenBreakdown(); live != 2 || expired != 2 {
+		t.Errorf("bad breakdown: %v, %v", live, expired)
+	}
+	if l.Len() != 4 {
+		t.Errorf("expired entries should not have been removed: %v", l.Len())
+	}
+}
+
+func TestLRU_PurgeWhere(t *testing.T) {
+	var evicted []int
+	l, err := NewLRU(16, func(k, v int) { evicted = append(evicted, k) })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		l.Add(i, i)
+	}
+	l.AddWithExp(10, 10, time.Now().Add(-time.Second))
+
+	even := func(k, v int) bool { return v%2 == 0 }
+	if n := l.PurgeWhere(even, true); n != 5 {
+		t.Errorf("bad removed count: %v", n)
+	}
+	if !reflect.DeepEqual(evicted, []int{0, 2, 4, 6, 8}) {
+		t.Errorf("bad callbacks: %v", evicted)
+	}
+	if !reflect.DeepEqual(l.Keys(), []int{1, 3, 5, 7, 9}) {
+		t.Errorf("bad keys: %v", l.Keys())
+	}
+
+	evicted = nil
+	if n := l.PurgeWhere(func(k, v int) bool { return k > 4 }, false); n != 3 {
+		t.Errorf("bad removed count: %v", n)
+	}
+	if len(evicted) != 0 {
+		t.Errorf("callback should not have been called: %v", evicted)
+	}
+	if !reflect.DeepEqual(l.Keys(), []int{1, 3}) {
+		t.Errorf("bad keys: %v", l.Keys())
+	}
+
+	// The callback is restored afterwards
+	l.Remove(1)
+	if !reflect.DeepEqual(evicted, []int{1}) {
+		t.Errorf("bad callbacks: %v", evicted)
+	}
+}
+
+func TestLRU_EntryAt(t *testing.T) {
+	l, err := NewLRU[int, int](16, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		l.Add(i, i)
+	}
+	l.ChangeExpiry(8, time.Now().Add(-time.Second))
+	l.ChangeExpiry(5, time.Now().Add(-time.Second))
+
+	// Positions count from the most recently used, skipping 8 and 5
+	for i, k := range []int{9, 7, 6, 4, 3, 2, 1, 0} {
+		e, ok := l.EntryAt(i)
+		if !ok || e.Key != k || e.Value != k {
+			t.Errorf("bad entry at %v: %+v, %v", i, e, ok)
+		}
+	}
+	for _, i := range []int{-1, 8, 100} {
+		if e, ok := l.EntryAt(i); ok {
+			t.Errorf("%v should be out of range: %+v", i, e)
+		}
+	}
+
+	keys := func(entries []Entry[int, int]) (keys []int) {
+		for _, e := range entries {
+			keys = append(keys, e.Key)
+		}
+		return keys
+	}
+	if k := keys(l.EntriesRange(2, 5)); !reflect.DeepEqual(k, []int{6, 4, 3}) {
+		t.Errorf("bad range: %v", k)
+	}
+	if k := keys(l.EntriesRange(6, 20)); !reflect.DeepEqual(k, []int{1, 0}) {
+		t.Errorf("bad range: %v", k)
+	}
+	if k := l.EntriesRange(5, 2); k != nil {
+		t.Errorf("bad empty range: %v", k)
+	}
+	if l.Len() != 10 {
+		t.Errorf("expired entries should have been left in place")
+	}
+}
+
+func TestLRU_FIFO(t *testing.T) {
+	for _, fifo := range []bool{false, true} {
+		var opts []Option[int, int]
+		if fifo {
+			opts = append(opts, WithFIFO[int, int]())
+		}
+		l, err := NewLRUWithEvictTTL(3, nil, time.Hour, opts...)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		l.Add(1, 1)
+		l.Add(2, 2)
+		l.Add(3, 3)
+		l.Get(1)
+		l.Add(2, 20)
+		l.Add(4, 4)
+
+		expected := []int{1, 2, 4}
+		if fifo {
+			expected = []int{2, 3, 4}
+		}
+		if !reflect.DeepEqual(l.Keys(), expected) {
+			t.Errorf("bad keys with fifo %v: %v", fifo, l.Keys())
+		}
+		if l.ExpiryForKey(4).IsZero() {
+			t.Errorf("TTL should apply with fifo %v", fifo)
+		}
+	}
+}
+
+func TestLRU_EvictTo(t *testing.T) {
+	var evicted []int
+	l, err := NewLRU(8, func(k, v int) { evicted = append(evicted, k) })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(i, i)
+	}
+	l.ChangeExpiry(5, time.Now().Add(-time.Second))
+
+	if n := l.EvictTo(10); n != 0 || l.Len() != 8 {
+		t.Errorf("nothing should have been evicted: %v", n)
+	}
+	if n := l.EvictTo(4); n != 4 {
+		t.Errorf("bad evicted count: %v", n)
+	}
+	if !reflect.DeepEqual(evicted, []int{0, 1, 2, 3}) || !reflect.DeepEqual(l.Keys(), []int{4, 6, 7}) {
+		t.Errorf("bad eviction: %v, %v", evicted, l.Keys())
+	}
+
+	// The size is unchanged
+	for i := 10; i < 15; i++ {
+		l.Add(i, i)
+	}
+	if l.Len() != 8 {
+		t.Errorf("bad len: %v", l.Len())
+	}
+
+	if n := l.EvictTo(-1); n != 8 || l.Len() != 0 {
+		t.Errorf("all entries should have been evicted: %v", n)
+	}
+}
+
+func TestNewFromMapOrdered(t *testing.T) {
+	m := map[string]int{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5}
+
+	l, err := NewFromMapOrdered(8, m, []string{"c", "x", "a", "e", "b", "d"}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(l.Keys(), []string{"c", "a", "e", "b", "d"}) {
+		t.Errorf("bad keys: %v", l.Keys())
+	}
+	if v, _ := l.Peek("e"); v != 5 {
+		t.Errorf("bad value for e: %v", v)
+	}
+
+	// Keys missing from keyOrder are the newest
+	l, err = NewFromMapOrdered(8, m, []string{"d", "b"}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if keys := l.Keys(); len(keys) != 5 || keys[0] != "d" || keys[1] != "b" {
+		t.Errorf("bad keys: %v", keys)
+	}
+
+	// Repeated keys keep their first position and are not evicted
+	var evicted []string
+	l, err = NewFromMapOrdered(8, m, []string{"c", "a", "c", "e", "b", "d", "a"},
+		func(k string, v int) { evicted = append(evicted, k) })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(l.Keys(), []string{"c", "a", "e", "b", "d"}) || len(evicted) != 0 {
+		t.Errorf("bad keys: %v, evicted: %v", l.Keys(), evicted)
+	}
+
+	l, err = NewFromMapOrdered(2, m, []string{"b", "d", "e", "a", "c"}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(l.Keys(), []string{"a", "c"}) {
+		t.Errorf("bad keys: %v", l.Keys())
+	}
+}
+
+func TestLRU_ValueCloner(t *testing.T) {
+	clone := func(v []int) []int {
+		return append([]int(nil), v...)
+	}
+	l, err := NewLRUWithEvictTTL(4, nil, 0, WithValueCloner[string, []int](clone))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("a", []int{1, 2, 3})
+
+	v, _ := l.Get("a")
+	v[0] = 100
+	v, _ = l.Peek("a")
+	v[1] = 200
+	l.Values()[0][2] = 300
+	if v, _ := l.Get("a"); !reflect.DeepEqual(v, []int{1, 2, 3}) {
+		t.Errorf("cached value should not have been modified: %v", v)
+	}
+
+	// Without a cloner the cached value is handed out
+	l, err = NewLRU[string, []int](4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add("a", []int{1, 2, 3})
+	v, _ = l.Get("a")
+	v[0] = 100
+	if v, _ := l.Get("a"); v[0] != 100 {
+		t.Errorf("cached value should have been modified: %v", v)
+	}
+}
+
+func TestLRU_ExpiredKeys(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(8, nil, 0, WithClock[int, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if keys := l.ExpiredKeys(); len(keys) != 0 {
+		t.Errorf("bad expired keys: %v", keys)
+	}
+
+	for i := 0; i < 6; i++ {
+		ttl := time.Hour
+		if i%2 == 1 {
+			ttl = time.Second
+		}
+		l.AddWithExp(i, i, clock.Now().Add(ttl))
+	}
+	l.Add(6, 6)
+	l.Get(1)
+	clock.Advance(time.Second * 2)
+
+	if keys := l.ExpiredKeys(); !reflect.DeepEqual(keys, []int{3, 5, 1}) {
+		t.Errorf("bad expired keys: %v", keys)
+	}
+	if l.Len() != 7 {
+		t.Errorf("expired entries should not have been removed: %v", l.Len())
+	}
+}
+
+func TestLRU_Tombstone(t *testing.T) {
+	clock := newFakeClock()
+	isTombstone := func(v string) bool { return v == "" }
+	l, err := NewLRUWithEvictTTL(4, nil, time.Minute,
+		WithClock[int, string](clock.Now), WithTombstone[int, string](isTombstone))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, "one")
+	l.Add(2, "")
+	if v, ok := l.Get(1); !ok || v != "one" {
+		t.Errorf("bad value for 1: %v, %v", v, ok)
+	}
+	if v, ok := l.Get(2); ok || v != "" {
+		t.Errorf("tombstone should be a miss: %v, %v", v, ok)
+	}
+	if r := l.Lookup(2); r.State != LookupTombstone {
+		t.Errorf("bad lookup of tombstone: %+v", r)
+	}
+	if !l.Contains(2) || l.Len() != 2 {
+		t.Errorf("tombstone should be in the cache")
+	}
+	if stats := l.Stats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+
+	clock.Advance(time.Minute * 2)
+	if r := l.Lookup(2); r.State != LookupExpired {
+		t.Errorf("tombstone should have expired: %+v", r)
+	}
+	if l.Contains(2) {
+		t.Errorf("expired tombstone should have been removed")
+	}
+}
+
+// Test that the expiries tracked to skip RemoveExpired stay correct
+func TestLRU_RemoveExpiredTracking(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(4, nil, 0, WithClock[int, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	if len(l.itemExpiries) != 0 {
+		t.Errorf("no entry should expire: %v", l.itemExpiries)
+	}
+
+	l.AddWithExp(3, 3, clock.Now().Add(time.Second))
+	l.ChangeExpiry(1, clock.Now().Add(time.Minute))
+	l.AddWithExp(4, 4, clock.Now().Add(time.Hour))
+	if len(l.itemExpiries) != 3 {
+		t.Errorf("bad expiring count: %v", l.itemExpiries)
+	}
+
+	l.Remove(4)
+	l.Add(5, 5)
+	if len(l.itemExpiries) != 2 {
+		t.Errorf("bad expiring count after removal and eviction: %v", l.itemExpiries)
+	}
+
+	clock.Advance(time.Second * 2)
+	if n := l.RemoveExpired(); n != 1 || len(l.itemExpiries) != 1 {
+		t.Errorf("3 should have expired: %v, %v", n, l.itemExpiries)
+	}
+	if !l.minExpiry.Equal(l.ExpiryForKey(1)) {
+		t.Errorf("the earliest expiry should have been raised: %v", l.minExpiry)
+	}
+	if n := l.RemoveExpired(); n != 0 {
+		t.Errorf("nothing should have expired: %v", n)
+	}
+
+	clock.Advance(time.Minute)
+	if n := l.RemoveExpired(); n != 1 || len(l.itemExpiries) != 0 {
+		t.Errorf("1 should have expired: %v, %v", n, l.itemExpiries)
+	}
+	if !reflect.DeepEqual(l.Keys(), []int{2, 5}) {
+		t.Errorf("bad keys: %v", l.Keys())
+	}
+}
+
+func TestLRU_OverrideOnce(t *testing.T) {
+	l, err := NewLRU[int, int](2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 1)
+	l.Add(2, 2)
+
+	l.OverrideOnce(1, 100)
+	if v, _ := l.Peek(1); v != 1 {
+		t.Errorf("Peek should return the cached value: %v", v)
+	}
+	if v, ok := l.Get(1); !ok || v != 100 {
+		t.Errorf("first Get should return the override: %v, %v", v, ok)
+	}
+	if v, ok := l.Get(1); !ok || v != 1 {
+		t.Errorf("second Get should return the cached value: %v, %v", v, ok)
+	}
+
+	// Overrides are dropped with their entry
+	l.OverrideOnce(2, 200)
+	l.Remove(2)
+	l.Add(2, 2)
+	if v, _ := l.Get(2); v != 2 {
+		t.Errorf("override should have been dropped: %v", v)
+	}
+
+	l.OverrideOnce(3, 300)
+	l.Add(3, 3)
+	if v, _ := l.Get(3); v != 3 {
+		t.Errorf("override of missing key should have been ignored: %v", v)
+	}
+}
+
+// Test that released list elements do not keep values alive
+func TestLRU_EntryPoolReleasesValues(t *testing.T) {
+	l, err := NewLRU[int, *benchValue](2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, &benchValue{})
+	l.Add(2, &benchValue{})
+	removed, expired := l.items[1], l.items[2]
+	l.Remove(1)
+	l.ChangeExpiry(2, time.Now().Add(-time.Second))
+	l.RemoveExpired()
+	for _, e := range []*entry[int, *benchValue]{removed, expired} {
+		if e.key != 0 || e.value != nil {
+			t.Errorf("released element should have been cleared: %+v", e)
+		}
+	}
+
+	// Released elements are reused
+	l.Add(3, &benchValue{})
+	if l.Len() != 1 || l.items[3].value == nil {
+		t.Errorf("bad entry for 3: %+v", l.items[3])
+	}
+}
+
+func TestLRU_AddReplacing(t *testing.T) {
+	clock := newFakeClock()
+	var evicted []int
+	l, err := NewLRUWithEvictTTL(2, func(k, v int) { evicted = append(evicted, k) }, time.Minute,
+		WithClock[int, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if old, existed, ev, _ := l.AddReplacing(1, 10, time.Time{}); old != 0 || existed || ev {
+		t.Errorf("bad result for new key: %v, %v, %v", old, existed, ev)
+	}
+	l.Add(2, 20)
+	clock.Advance(time.Second * 30)
+
+	// Replacing at capacity neither evicts nor calls the callback
+	if old, existed, ev, _ := l.AddReplacing(1, 11, time.Time{}); old != 10 || !existed || ev {
+		t.Errorf("bad result for existing key: %v, %v, %v", old, existed, ev)
+	}
+	if len(evicted) != 0 {
+		t.Errorf("replaced value should not be passed to the callback: %v", evicted)
+	}
+	if v, _ := l.Peek(1); v != 11 {
+		t.Errorf("bad value: %v", v)
+	}
+	if !l.ExpiryForKey(1).Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("expiry should have been reset: %v", l.ExpiryForKey(1))
+	}
+
+	// Adding a new key at capacity evicts another entry
+	if old, existed, ev, _ := l.AddReplacing(3, 30, time.Time{}); existed || !ev {
+		t.Errorf("bad result for new key at capacity: %v, %v, %v", old, existed, ev)
+	}
+	if !reflect.DeepEqual(evicted, []int{2}) {
+		t.Errorf("2 should have been evicted: %v", evicted)
+	}
+
+	// Expired entries are not replaced
+	clock.Advance(time.Minute * 2)
+	if old, existed, _, _ := l.AddReplacing(3, 31, time.Time{}); old != 0 || existed {
+		t.Errorf("expired value should not be returned: %v, %v", old, existed)
+	}
+	if v, ok := l.Get(3); !ok || v != 31 {
+		t.Errorf("bad value after replacing expired entry: %v, %v", v, ok)
+	}
+}
+
+func TestLRU_AddReplacingRejected(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(2, nil, time.Minute, WithClock[int, int](clock.Now),
+		WithMaxTTL[int, int](time.Hour), WithRejectOverMaxTTL[int, int](),
+		WithWriteThrottle[int, int](time.Second))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(1, 10)
+
+	// A rejected update is reported and leaves the entry as it was
+	old, existed, _, err := l.AddReplacing(1, 11, clock.Now().Add(time.Hour*2))
+	if err != ErrExpiryTooLate || old != 0 || existed {
+		t.Errorf("bad result for rejected update: %v, %v, %v", old, existed, err)
+	}
+	if v, _ := l.Peek(1); v != 10 {
+		t.Errorf("value should be unchanged: %v", v)
+	}
+
+	// Updates within the throttle interval keep the expiry
+	expiry := l.ExpiryForKey(1)
+	clock.Advance(time.Second / 2)
+	if old, existed, _, err := l.AddReplacing(1, 12, time.Time{}); err != nil || old != 10 || !existed {
+		t.Errorf("bad result for throttled update: %v, %v, %v", old, existed, err)
+	}
+	if v, _ := l.Peek(1); v != 12 || !l.ExpiryForKey(1).Equal(expiry) {
+		t.Errorf("throttled update should only replace the value: %v, %v", v, l.ExpiryForKey(1))
+	}
+}
+
+func TestLRU_MaxTTL(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(8, nil, time.Duration(math.MaxInt64),
+		WithClock[int, int64](clock.Now), WithMaxTTL[int, int64](time.Hour))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	latest := clock.Now().Add(time.Hour)
+
+	l.Add(1, 1)
+	l.AddWithExp(2, 2, time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC))
+	l.AddWithExp(3, 3, clock.Now().Add(time.Minute))
+	IncrAndGet(l, 4, time.Duration(math.MaxInt64))
+	for _, k := range []int{1, 2, 4} {
+		if !l.ExpiryForKey(k).Equal(latest) {
+			t.Errorf("expiry of %v should have been clamped: %v", k, l.ExpiryForKey(k))
+		}
+	}
+	if !l.ExpiryForKey(3).Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("expiry of 3 should be unchanged: %v", l.ExpiryForKey(3))
+	}
+
+	// Non-positive TTLs mean no expiry
+	if n := l.RefreshAllTTL(-time.Hour); n != 4 || !l.ExpiryForKey(1).IsZero() {
+		t.Errorf("entries should no longer expire: %v", l.ExpiryForKey(1))
+	}
+	if n := l.RefreshAllTTL(time.Duration(math.MaxInt64)); n != 4 || !l.ExpiryForKey(1).Equal(latest) {
+		t.Errorf("expiry should have been clamped: %v", l.ExpiryForKey(1))
+	}
+
+	if _, err := NewLRUWithEvictTTL(8, nil, 0, WithMaxTTL[int, int](0)); err == nil {
+		t.Errorf("should have failed for a zero max TTL")
+	}
+}
+
+func TestLRU_MaxTTLGranularity(t *testing.T) {
+	clock := newFakeClock()
+	clock.Advance(time.Second / 2)
+	l, err := NewLRUWithEvictTTL(8, nil, 0, WithClock[int, int](clock.Now),
+		WithMaxTTL[int, int](time.Hour), WithExpiryGranularity[int, int](time.Second))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Rounding up to the granularity must not exceed the max TTL
+	latest := clock.Now().Add(time.Hour)
+	l.AddWithExp(1, 1, latest)
+	if !l.ExpiryForKey(1).Equal(latest) {
+		t.Errorf("expiry should have been clamped after rounding: %v", l.ExpiryForKey(1))
+	}
+}
+
+func TestLRU_RejectOverMaxTTL(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(8, nil, 0, WithClock[int, int](clock.Now),
+		WithMaxTTL[int, int](time.Hour), WithRejectOverMaxTTL[int, int]())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	latest := clock.Now().Add(time.Hour)
+
+	if _, err := l.AddChecked(1, 1, latest.Add(time.Nanosecond)); err != ErrExpiryTooLate {
+		t.Errorf("bad error: %v", err)
+	}
+	l.AddWithExp(2, 2, time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC))
+	if l.Contains(1) || l.Contains(2) {
+		t.Errorf("entries beyond the max TTL should have been rejected: %v", l.Keys())
+	}
+
+	if _, err := l.AddChecked(1, 1, latest); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Add(2, 2)
+	if !l.ExpiryForKey(1).Equal(latest) || !l.ExpiryForKey(2).IsZero() {
+		t.Errorf("bad expiries: %v, %v", l.ExpiryForKey(1), l.ExpiryForKey(2))
+	}
+
+	// Rejected updates leave the entries unchanged
+	if l.AddWithExp(1, 11, latest.Add(time.Minute)); l.ExpiryForKey(1) != latest {
+		t.Errorf("expiry should be unchanged: %v", l.ExpiryForKey(1))
+	}
+	if v, _ := l.Peek(1); v != 1 {
+		t.Errorf("value should be unchanged: %v", v)
+	}
+	if l.ChangeExpiry(2, latest.Add(time.Minute)) || !l.ExpiryForKey(2).IsZero() {
+		t.Errorf("expiry change should have been rejected: %v", l.ExpiryForKey(2))
+	}
+	if n := l.RefreshAllTTL(2 * time.Hour); n != 0 || !l.ExpiryForKey(2).IsZero() {
+		t.Errorf("refresh should have been rejected: %v", n)
+	}
+	if n := l.RefreshAllTTL(time.Minute); n != 2 || !l.ExpiryForKey(2).Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("refresh within the max TTL should apply: %v", n)
+	}
+}
+
+func TestLRU_PeekMulti(t *testing.T) {
+	l, err := NewLRU[int, int](8, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		l.Add(i, i*10)
+	}
+	l.ChangeExpiry(3, time.Now().Add(-time.Second))
+
+	values := l.PeekMulti([]int{0, 2, 3, 7})
+	if !reflect.DeepEqual(values, map[int]int{0: 0, 2: 20}) {
+		t.Errorf("bad values: %v", values)
+	}
+	if l.Len() != 5 || l.Stats() != (Stats{}) {
+		t.Errorf("cache should not have been modified: %v, %+v", l.Len(), l.Stats())
+	}
+	if k, _, _ := l.GetOldest(); k != 0 {
+		t.Errorf("recency should be unchanged: %v", l.Keys())
+	}
+}
+
+func TestLRU_CreatedAt(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(2, nil, 0, WithClock[int, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	start := clock.Now()
+	l.Add(1, 1)
+	clock.Advance(time.Second)
+	l.Add(2, 2)
+	l.Get(1)
+	clock.Advance(time.Second)
+
+	if created, ok := l.CreatedAt(1); !ok || !created.Equal(start) {
+		t.Errorf("bad creation time of 1: %v, %v", created, ok)
+	}
+	if created, ok := l.CreatedAt(2); !ok || !created.Equal(start.Add(time.Second)) {
+		t.Errorf("bad creation time of 2: %v, %v", created, ok)
+	}
+
+	// Adding again resets the creation time
+	l.Add(1, 10)
+	if created, _ := l.CreatedAt(1); !created.Equal(clock.Now()) {
+		t.Errorf("creation time of 1 should have been reset: %v", created)
+	}
+
+	// Evicted elements reused for new entries get a new creation time
+	clock.Advance(time.Second)
+	l.Add(3, 3)
+	if created, ok := l.CreatedAt(3); !ok || !created.Equal(clock.Now()) {
+		t.Errorf("bad creation time of 3: %v, %v", created, ok)
+	}
+	if _, ok := l.CreatedAt(2); ok {
+		t.Errorf("evicted 2 should have no creation time")
+	}
+}
+
+func TestLRU_SetOnEvent(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(2, nil, 0, WithClock[int, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var events []Event[int, int]
+	l.SetOnEvent(func(ev Event[int, int]) {
+		events = append(events, ev)
+	})
+
+	l.Add(1, 1)
+	l.Add(1, 10)
+	l.AddWithExp(2, 2, clock.Now().Add(time.Second))
+	l.Add(3, 3)
+	l.Remove(3)
+	clock.Advance(time.Second * 2)
+	l.Get(2)
+	l.Add(4, 4)
+	l.Add(5, 5)
+	l.Purge()
+
+	expected := []Event[int, int]{
+		{EventAdd, 1, 1},
+		{EventUpdate, 1, 10},
+		{EventAdd, 2, 2},
+		{EventEvict, 1, 10},
+		{EventAdd, 3, 3},
+		{EventRemove, 3, 3},
+		{EventExpire, 2, 2},
+		{EventAdd, 4, 4},
+		{EventAdd, 5, 5},
+		{EventPurge, 4, 4},
+		{EventPurge, 5, 5},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("bad events: %v", events)
+	}
+	if EventExpire.String() != "expire" {
+		t.Errorf("bad event type name: %v", EventExpire)
+	}
+
+	l.SetOnEvent(nil)
+	l.Add(6, 6)
+	if len(events) != len(expected) {
+		t.Errorf("should not report events after removing the function")
+	}
+}
+
+func TestLRU_Occupancy(t *testing.T) {
+	l, err := NewLRU[int, int](4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if o := l.Occupancy(); o != 0 {
+		t.Errorf("empty cache should have no occupancy: %v", o)
+	}
+
+	l.Add(1, 1)
+	l.AddWithExp(2, 2, time.Now().Add(-time.Second))
+	l.Add(3, 3)
+	if o := l.Occupancy(); o != 0.5 {
+		t.Errorf("expired entries should not count: %v", o)
+	}
+
+	for i := 4; i < 8; i++ {
+		l.Add(i, i)
+	}
+	if o := l.Occupancy(); o != 1 {
+		t.Errorf("full cache should have full occupancy: %v", o)
+	}
+
+	l.Resize(0)
+	if o := l.Occupancy(); o != 1 {
+		t.Errorf("cache without capacity should have full occupancy: %v", o)
+	}
+}
+
+func TestLRU_RangeAndReap(t *testing.T) {
+	var evicted []int
+	l, err := NewLRU(8, func(k, v int) { evicted = append(evicted, k) })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	past := time.Now().Add(-time.Second)
+	l.Add(1, 10)
+	l.AddWithExp(2, 20, past)
+	l.Add(3, 30)
+	l.AddWithExp(4, 40, past)
+	l.Add(5, 50)
+	l.AddWithExp(6, 60, past)
+
+	// Stopping early leaves the later expired entries
+	var visited []int
+	reaped := l.RangeAndReap(func(k, v int) bool {
+		if v != k*10 {
+			t.Errorf("bad value of %d: %d", k, v)
+		}
+		visited = append(visited, k)
+		return k < 3
+	})
+	if reaped != 1 || !reflect.DeepEqual(visited, []int{1, 3}) {
+		t.Errorf("bad early stop: reaped %d, visited %v", reaped, visited)
+	}
+	if !reflect.DeepEqual(evicted, []int{2}) || l.Len() != 5 {
+		t.Errorf("only 2 should have been reaped: %v", evicted)
+	}
+
+	visited = nil
+	reaped = l.RangeAndReap(func(k, v int) bool {
+		visited = append(visited, k)
+		return true
+	})
+	if reaped != 2 || !reflect.DeepEqual(visited, []int{1, 3, 5}) {
+		t.Errorf("bad full pass: reaped %d, visited %v", reaped, visited)
+	}
+	if !reflect.DeepEqual(evicted, []int{2, 4, 6}) || l.Len() != 3 {
+		t.Errorf("all expired entries should have been reaped: %v", evicted)
+	}
+	if keys := l.Keys(); !reflect.DeepEqual(keys, []int{1, 3, 5}) {
+		t.Errorf("ranging should not update recent-ness: %v", keys)
+	}
+}
+
+func TestLRU_Expire(t *testing.T) {
+	var evicted []int
+	l, err := NewLRUWithEvictTTL(4, func(k, v int) { evicted = append(evicted, k) }, 0,
+		WithEvictedRecord[int, int](4))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var events []EventType
+	l.SetOnEvent(func(ev Event[int, int]) {
+		events = append(events, ev.Type)
+	})
+
+	l.Add(1, 1)
+	l.AddWithExp(2, 2, time.Now().Add(-time.Second))
+	events = nil
+
+	if !l.Expire(1) {
+		t.Errorf("1 should have been expired")
+	}
+	if l.Expire(2) {
+		t.Errorf("2 had already expired")
+	}
+	if l.Expire(3) {
+		t.Errorf("3 is not in the cache")
+	}
+
+	if _, ok := l.Get(1); ok {
+		t.Errorf("1 should be a miss")
+	}
+	if l.Len() != 0 {
+		t.Errorf("expired entries should have been removed: %v", l.Len())
+	}
+	if !reflect.DeepEqual(evicted, []int{1, 2}) {
+		t.Errorf("bad evictions: %v", evicted)
+	}
+	if !reflect.DeepEqual(events, []EventType{EventExpire, EventExpire}) {
+		t.Errorf("bad events: %v", events)
+	}
+	if stats := l.Stats(); stats.Expirations != 2 || stats.Evictions != 0 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+	if !l.WasRecentlyEvicted(1) {
+		t.Errorf("1 should have been recorded as expired")
+	}
+}
+
+// Test that WithKeyValidator rejects invalid keys
+func TestLRU_KeyValidator(t *testing.T) {
+	errBadKey := errors.New("bad key")
+	validate := func(key string) error {
+		if !strings.HasPrefix(key, "/") {
+			return errBadKey
+		}
+		return nil
+	}
+	l, err := NewLRUWithEvictTTL(2, nil, 0, WithKeyValidator[string, int](validate))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, err := l.AddChecked("a", 1, time.Time{}); err != errBadKey {
+		t.Errorf("a should have been rejected: %v", err)
+	}
+	l.Add("b", 2)
+	l.AddWithExp("c", 3, time.Now().Add(time.Minute))
+	if l.Len() != 0 {
+		t.Errorf("invalid keys should not have been added: %v", l.Keys())
+	}
+
+	if _, err := l.AddChecked("/a", 1, time.Time{}); err != nil {
+		t.Errorf("/a should have been accepted: %v", err)
+	}
+	l.Add("/b", 2)
+	if v, ok := l.Get("/a"); !ok || v != 1 {
+		t.Errorf("/a should be set to 1: %v, %v", v, ok)
+	}
+	if v, ok := l.Get("/b"); !ok || v != 2 {
+		t.Errorf("/b should be set to 2: %v, %v", v, ok)
+	}
+
+	if _, err := NewLRUWithEvictTTL(2, nil, 0, WithKeyValidator[string, int](nil)); err == nil {
+		t.Errorf("should have failed without a validator")
+	}
+}
+
+func TestLRU_RetainFunc(t *testing.T) {
+	var evicted []int
+	l, err := NewLRU(8, func(k, v int) { evicted = append(evicted, k) })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 1; i <= 6; i++ {
+		if i == 3 {
+			l.AddWithDeps(i, i, []int{2})
+			continue
+		}
+		l.Add(i, i)
+	}
+	l.AddWithExp(7, 7, time.Now().Add(-time.Second))
+
+	// Removing 2 removes its dependent 3 as well, which must neither
+	// be visited nor make the walk visit 1 again
+	visits := make(map[int]int)
+	removed := l.RetainFunc(func(k, v int) bool {
+		visits[k]++
+		return v%2 == 1
+	})
+	if removed != 3 {
+		t.Errorf("bad removal count: %v", removed)
+	}
+	if !reflect.DeepEqual(visits, map[int]int{1: 1, 2: 1, 4: 1, 5: 1, 6: 1}) {
+		t.Errorf("bad visits: %v", visits)
+	}
+	if !reflect.DeepEqual(evicted, []int{2, 3, 4, 6}) {
+		t.Errorf("bad evictions: %v", evicted)
+	}
+	if keys := l.Keys(); !reflect.DeepEqual(keys, []int{1, 5}) {
+		t.Errorf("bad retained keys: %v", keys)
+	}
+}
+
+// physicalKeys returns the keys of all entries, including expired ones,
+// from oldest to newest.
+func physicalKeys[K comparable, V any](c *LRU[K, V]) []K {
+	var keys []K
+	for ent := c.evictList.back(); ent != nil; ent = ent.prevEntry() {
+		keys = append(keys, ent.key)
+	}
+	return keys
+}
+
+// FuzzLRU_Invariants runs random sequences of operations, three bytes each,
+// against a cache with a fake clock and checks after each one that:
+//   - the keys are ordered by when they were last added or read,
+//   - removing expired entries does not reorder the others,
+//   - ExpiryForKey agrees with KeyHasExpired,
+//   - adding a key sets its expiry, also when updating it.
+func FuzzLRU_Invariants(f *testing.F) {
+	f.Add([]byte{0, 1, 0, 1, 2, 5, 2, 1, 0, 4, 0, 20, 0, 2, 0})
+	f.Add([]byte{1, 1, 3, 0, 2, 0, 3, 1, 1, 4, 0, 5, 2, 1, 0, 5, 0, 0})
+	f.Add([]byte{1, 1, 30, 1, 1, 2, 4, 0, 3, 2, 1, 0, 0, 3, 0, 0, 4, 0, 0, 5, 0, 2, 0, 6})
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		const ttl = time.Second * 10
+		clock := newFakeClock()
+		l, err := NewLRUWithEvictTTL[int, int](4, nil, ttl, WithClock[int, int](clock.Now))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		lastUse := make(map[int]int)
+
+		for i := 0; i+2 < len(ops); i += 3 {
+			key, arg := int(ops[i+1]%8), time.Duration(ops[i+2]%32)*time.Second
+			switch ops[i] % 6 {
+			case 0:
+				l.Add(key, i)
+				lastUse[key] = i
+				if exp := l.ExpiryForKey(key); !exp.Equal(clock.Now().Add(ttl)) {
+					t.Fatalf("op %d: Add(%d) should set the expiry to the TTL: %v", i, key, exp)
+				}
+			case 1:
+				// arg is never 0, as a zero expiry means the TTL
+				expiry := clock.Now().Add(arg - time.Second*15)
+				l.AddWithExp(key, i, expiry)
+				lastUse[key] = i
+				if exp := l.ExpiryForKey(key); !exp.Equal(expiry) {
+					t.Fatalf("op %d: AddWithExp(%d) should set the expiry to %v: %v", i, key, expiry, exp)
+				}
+			case 2:
+				if _, ok := l.Get(key); ok {
+					lastUse[key] = i
+				}
+			case 3:
+				l.ChangeExpiry(key, clock.Now().Add(arg))
+			case 4:
+				clock.Advance(arg)
+			case 5:
+				before := physicalKeys(l)
+				l.RemoveExpired()
+				var survivors []int
+				for _, k := range before {
+					if _, ok := l.items[k]; ok {
+						survivors = append(survivors, k)
+					}
+				}
+				if after := physicalKeys(l); !reflect.DeepEqual(after, survivors) {
+					t.Fatalf("op %d: removing expired entries reordered %v to %v", i, survivors, after)
+				}
+			}
+
+			now := clock.Now()
+			for _, k := range physicalKeys(l) {
+				exp := l.ExpiryForKey(k)
+				if expired := !exp.IsZero() && exp.Before(now); expired != l.KeyHasExpired(k) {
+					t.Fatalf("op %d: expiry %v of %d disagrees with KeyHasExpired", i, exp, k)
+				}
+			}
+			keys := l.Keys()
+			for j := 1; j < len(keys); j++ {
+				if lastUse[keys[j-1]] >= lastUse[keys[j]] {
+					t.Fatalf("op %d: keys are not ordered by last use: %v, %v", i, keys, lastUse)
+				}
+			}
+		}
+	})
 }
\ No newline at end of file
@@ -0,0 +1,247 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// Option configures optional behaviour of an LRU on construction.
+type Option[K comparable, V any] func(*LRU[K, V]) error
+
+// WithEvictedRecord makes the cache remember the keys of up to size entries
+// it most recently evicted or expired, which can be queried with
+// WasRecentlyEvicted. Entries removed with Remove or Purge are not recorded.
+func WithEvictedRecord[K comparable, V any](size int) Option[K, V] {
+	return func(c *LRU[K, V]) (err error) {
+		if size <= 0 {
+			return errors.New("must provide a positive evicted record size")
+		}
+		c.evicted, err = NewLRU[K, struct{}](size, nil)
+		return err
+	}
+}
+
+// WithAdmission makes the cache reject new entries that would evict an entry
+// which has been accessed more often recently, so that keys which are only
+// seen once cannot push out frequently used ones. Access frequencies are
+// estimated from the given key hash with a small count-min sketch, and are
+// raised for keys found by WasRecentlyEvicted. Admission is only checked
+// when the cache is full; Add returns false for rejected entries.
+func WithAdmission[K comparable, V any](hash func(K) uint64) Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		if hash == nil {
+			return errors.New("must provide a hash function")
+		}
+		c.admission = newCountMinSketch(c.size, hash)
+		return nil
+	}
+}
+
+// WithSizer sets a function returning the size of a value in bytes, which is
+// used to sum the sizes of evicted and expired values for BytesEvicted.
+func WithSizer[K comparable, V any](sizer func(V) int64) Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		c.sizer = sizer
+		return nil
+	}
+}
+
+// WithRandSeed seeds the random number generator used by RandomKey and
+// RandomKeys, making the keys they return reproducible.
+func WithRandSeed[K comparable, V any](seed int64) Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		c.rng = rand.New(rand.NewSource(seed))
+		return nil
+	}
+}
+
+// WithBeforeEvict sets a function which is called with the entry chosen to
+// make room in the cache, before it is evicted. If it returns true the entry
+// is rescued: it is moved to the front and the next oldest entry is offered
+// instead. Once every entry has been rescued in turn, the oldest one is
+// evicted regardless.
+func WithBeforeEvict[K comparable, V any](rescue func(key K, value V) bool) Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		c.beforeEvict = rescue
+		return nil
+	}
+}
+
+// WithKeepExpiredOnGet makes Get report a miss for an expired entry without
+// removing it, leaving it to be reaped by RemoveExpired or by eviction.
+// Such reads are counted in Stats.ExpiredReads.
+func WithKeepExpiredOnGet[K comparable, V any]() Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		c.keepExpiredOnGet = true
+		return nil
+	}
+}
+
+// WithExpiryGranularity rounds every expiry up to a multiple of granularity,
+// so that entries added around the same time share the same expiry.
+// Entries may therefore live up to one granularity longer than requested.
+func WithExpiryGranularity[K comparable, V any](granularity time.Duration) Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		if granularity < 0 {
+			return errors.New("must provide a non-negative expiry granularity")
+		}
+		c.expiryGranularity = granularity
+		return nil
+	}
+}
+
+// WithRejectZeroKey prevents adding entries with the zero value as key, which
+// usually indicates a bug. Add and AddWithExp drop such entries, while
+// AddChecked returns ErrZeroKey for them.
+func WithRejectZeroKey[K comparable, V any]() Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		c.rejectZeroKey = true
+		return nil
+	}
+}
+
+// WithKeyValidator sets a function which is called with every key about to
+// be added to the cache, to reject malformed keys. Add and AddWithExp drop
+// entries whose key it returns an error for, while AddChecked returns the
+// error. Keys which are already in the cache are checked as well when
+// updated.
+func WithKeyValidator[K comparable, V any](validate func(key K) error) Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		if validate == nil {
+			return errors.New("must provide a key validator")
+		}
+		c.validateKey = validate
+		return nil
+	}
+}
+
+// WithClock sets the function used to get the current time, which is
+// time.Now by default.
+func WithClock[K comparable, V any](now func() time.Time) Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		if now == nil {
+			return errors.New("must provide a clock")
+		}
+		c.now = now
+		return nil
+	}
+}
+
+// WithStatsWindow keeps the times of the last size operations counted in
+// Stats, so that WindowedStats can count those within a trailing window.
+// This costs memory for size operations and a clock read per operation.
+func WithStatsWindow[K comparable, V any](size int) Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		if size <= 0 {
+			return errors.New("must provide a positive stats window size")
+		}
+		c.events = newEventRing(size)
+		return nil
+	}
+}
+
+// WithEvictionPolicy makes the cache ask policy which entry to evict when it
+// needs to make room, instead of evicting the least recently used one.
+// Expired entries are still removed first, and WithBeforeEvict has no effect.
+// The order of Keys, Values and RemoveOldest is not affected by the policy.
+func WithEvictionPolicy[K comparable, V any](policy EvictionPolicy[K]) Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		if policy == nil {
+			return errors.New("must provide an eviction policy")
+		}
+		c.policy = policy
+		return nil
+	}
+}
+
+// WithWriteThrottle coalesces repeated updates of a key: after an entry has
+// been added or updated, further updates of it within d only replace its
+// value. They neither call the eviction callback with the previous value nor
+// make the entry the most recently used. The next update after d is handled
+// as usual and starts a new interval.
+func WithWriteThrottle[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		if d < 0 {
+			return errors.New("must provide a non-negative write throttle")
+		}
+		c.writeThrottle = d
+		return nil
+	}
+}
+
+// WithVictimObserver sets a function which is called with the key of each
+// entry chosen to make room in the cache, and the reason it was chosen, right
+// before it is removed. Unlike the eviction callback it is not called for
+// entries removed otherwise, which makes it useful to debug eviction.
+func WithVictimObserver[K comparable, V any](observe func(key K, reason EvictReason)) Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		c.observeVictim = observe
+		return nil
+	}
+}
+
+// WithFIFO makes the cache evict entries in the order in which they were
+// added, as Get and updates by Add do not make an entry the most recently
+// used one. Expiry and the other options work as usual.
+func WithFIFO[K comparable, V any]() Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		c.fifo = true
+		return nil
+	}
+}
+
+// WithValueCloner sets a function returning a copy of a value, which is used
+// to hand out copies of the cached values from Get, Lookup, Peek,
+// PeekPhysical, GetOldest and Values. Callers can then modify the values
+// they receive without changing the cached ones, for example when V is a
+// pointer, slice or map. This costs a copy on every such call; the values
+// passed to callbacks and returned by other methods are not copied.
+func WithValueCloner[K comparable, V any](clone func(V) V) Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		c.cloneValue = clone
+		return nil
+	}
+}
+
+// WithTombstone sets a function telling values which mark a key as absent,
+// for negative caching. Get reports a miss for entries with such a value,
+// which Lookup returns as LookupTombstone, while they still take up space,
+// count for Contains and Peek and expire as usual.
+func WithTombstone[K comparable, V any](isTombstone func(V) bool) Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		c.isTombstone = isTombstone
+		return nil
+	}
+}
+
+// WithMaxTTL limits the expiry of every entry to at most maxTTL from the time
+// it is set, whether it comes from the TTL of the cache, an explicit expiry
+// or another method setting expiries, so that a miscomputed TTL cannot keep
+// an entry forever. Entries which never expire are not affected: as
+// everywhere else, a TTL which is not positive means no expiry. Expiries
+// beyond the limit are clamped to it, unless WithRejectOverMaxTTL is given.
+func WithMaxTTL[K comparable, V any](maxTTL time.Duration) Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		if maxTTL <= 0 {
+			return errors.New("must provide a positive max TTL")
+		}
+		c.maxTTL = maxTTL
+		return nil
+	}
+}
+
+// WithRejectOverMaxTTL makes the cache reject expiries beyond the max TTL
+// set by WithMaxTTL instead of clamping them: AddWithExp drops such
+// entries, AddChecked returns ErrExpiryTooLate for them, and ChangeExpiry
+// and RefreshAllTTL leave the entries unchanged. The TTL of the cache
+// itself is still clamped. It has no effect without WithMaxTTL.
+func WithRejectOverMaxTTL[K comparable, V any]() Option[K, V] {
+	return func(c *LRU[K, V]) error {
+		c.rejectOverMaxTTL = true
+		return nil
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+// EvictionPolicy chooses the entries an LRU evicts to make room, see
+// WithEvictionPolicy. The LRU informs the policy of every key it inserts,
+// accesses and removes, and asks it for a victim when it is full.
+type EvictionPolicy[K comparable] interface {
+	// RecordInsert is called when an entry for key is added.
+	RecordInsert(key K)
+	// RecordAccess is called when the entry for key is returned by Get
+	// or updated by Add.
+	RecordAccess(key K)
+	// RecordRemove is called when the entry for key is removed
+	// for any reason, including eviction and Purge.
+	RecordRemove(key K)
+	// Victim returns the key of the entry to evict. It is only called
+	// while the cache holds entries, and must return one of their keys.
+	// With WithAdmission it is also asked for the entry a new one would
+	// replace, which is not evicted if the new entry is rejected.
+	Victim() K
+}
+
+// victim returns the entry chosen by the eviction policy, falling back
+// to the given oldest entry if the policy returns an unknown key.
+func (c *LRU[K, V]) victim(oldest *entry[K, V]) *entry[K, V] {
+	if ent, ok := c.items[c.policy.Victim()]; ok {
+		return ent
+	}
+	return oldest
+}
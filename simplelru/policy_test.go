@@ -0,0 +1,131 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// stubPolicy records the calls made to it and evicts the largest key.
+type stubPolicy struct {
+	calls []string
+	keys  map[int]struct{}
+}
+
+func newStubPolicy() *stubPolicy {
+	return &stubPolicy{keys: make(map[int]struct{})}
+}
+
+func (p *stubPolicy) RecordInsert(key int) {
+	p.calls = append(p.calls, fmt.Sprintf("insert %d", key))
+	p.keys[key] = struct{}{}
+}
+
+func (p *stubPolicy) RecordAccess(key int) {
+	p.calls = append(p.calls, fmt.Sprintf("access %d", key))
+}
+
+func (p *stubPolicy) RecordRemove(key int) {
+	p.calls = append(p.calls, fmt.Sprintf("remove %d", key))
+	delete(p.keys, key)
+}
+
+func (p *stubPolicy) Victim() int {
+	p.calls = append(p.calls, "victim")
+	victim := -1
+	for k := range p.keys {
+		if k > victim {
+			victim = k
+		}
+	}
+	return victim
+}
+
+func (p *stubPolicy) takeCalls() []string {
+	calls := p.calls
+	p.calls = nil
+	return calls
+}
+
+func TestLRU_EvictionPolicy(t *testing.T) {
+	p := newStubPolicy()
+	var evicted []int
+	l, err := NewLRUWithEvictTTL(3, func(k, v int) { evicted = append(evicted, k) }, 0,
+		WithEvictionPolicy[int, int](p))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(3, 3)
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Get(3)
+	l.Peek(1)
+	l.Contains(2)
+	if calls := p.takeCalls(); !reflect.DeepEqual(calls, []string{"insert 3", "insert 1", "insert 2", "access 3"}) {
+		t.Errorf("bad calls: %v", calls)
+	}
+
+	// The policy evicts 3 although it was used most recently
+	evicted = nil
+	if !l.Add(4, 4) {
+		t.Errorf("should have evicted")
+	}
+	if calls := p.takeCalls(); !reflect.DeepEqual(calls, []string{"victim", "remove 3", "insert 4"}) {
+		t.Errorf("bad calls: %v", calls)
+	}
+	if !reflect.DeepEqual(evicted, []int{3}) || l.Contains(3) {
+		t.Errorf("3 should have been evicted: %v", evicted)
+	}
+
+	l.Add(1, 10)
+	l.Remove(2)
+	l.Rename(1, 5)
+	if calls := p.takeCalls(); !reflect.DeepEqual(calls, []string{"access 1", "remove 2", "remove 1", "insert 5"}) {
+		t.Errorf("bad calls: %v", calls)
+	}
+
+	l.Purge()
+	if len(p.keys) != 0 || l.Len() != 0 {
+		t.Errorf("policy should have been told about purged keys: %v", p.keys)
+	}
+
+	if _, err := NewLRUWithEvictTTL(3, nil, 0, WithEvictionPolicy[int, int](nil)); err == nil {
+		t.Errorf("should have failed without a policy")
+	}
+}
+
+// Test that admission compares new keys with the victim of the policy
+func TestLRU_EvictionPolicyAdmission(t *testing.T) {
+	p := newStubPolicy()
+	l, err := NewLRUWithEvictTTL(3, nil, 0,
+		WithEvictionPolicy[int, int](p), WithAdmission[int, int](hashInt))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	for i := 0; i < 5; i++ {
+		l.Get(3)
+	}
+
+	// 4 is rejected as the policy would evict 3, which is used often,
+	// although the oldest entry 1 is not
+	if _, err := l.AddChecked(4, 4, time.Time{}); err != ErrNotAdmitted || l.Contains(4) || !l.Contains(3) {
+		t.Errorf("4 should have been rejected: %v, %v", err, l.Keys())
+	}
+
+	// Once 4 is used more often than 3 it replaces it
+	for i := 0; i < 10; i++ {
+		l.Get(4)
+	}
+	if l.Add(4, 4); !l.Contains(4) || l.Contains(3) || !l.Contains(1) {
+		t.Errorf("4 should have replaced 3: %v", l.Keys())
+	}
+}
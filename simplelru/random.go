@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"math/rand"
+	"time"
+)
+
+// RandomKey returns a uniformly chosen key of an entry that has not expired.
+// As the cache has no index to pick from, this walks the whole list using
+// reservoir sampling, so it costs O(n).
+func (c *LRU[K, V]) RandomKey() (key K, ok bool) {
+	keys := c.RandomKeys(1)
+	if len(keys) == 0 {
+		return
+	}
+	return keys[0], true
+}
+
+// RandomKeys returns up to n distinct keys of entries that have not expired,
+// chosen uniformly at random and in no particular order. Like RandomKey,
+// this walks the whole list.
+func (c *LRU[K, V]) RandomKeys(n int) []K {
+	if n <= 0 {
+		return nil
+	}
+	// Allocate no more than the cache can return, even for a huge n
+	if l := c.evictList.length(); n > l {
+		n = l
+	}
+
+	rng := c.random()
+	keys := make([]K, 0, n)
+	seen := 0
+	for ent := c.evictList.back(); ent != nil; ent = ent.prevEntry() {
+		if c.KeyHasExpired(ent.key) {
+			continue
+		}
+		seen++
+		if len(keys) < n {
+			keys = append(keys, ent.key)
+		} else if i := rng.Intn(seen); i < n {
+			keys[i] = ent.key
+		}
+	}
+	return keys
+}
+
+// random returns the random number generator of the cache,
+// creating one seeded from the current time if none was set.
+func (c *LRU[K, V]) random() *rand.Rand {
+	if c.rng == nil {
+		c.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return c.rng
+}
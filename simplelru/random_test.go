@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLRU_RandomKey(t *testing.T) {
+	l, err := NewLRUWithEvictTTL(8, nil, 0, WithRandSeed[int, int](1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, ok := l.RandomKey(); ok {
+		t.Errorf("empty cache should have no random key")
+	}
+
+	for i := 0; i < 4; i++ {
+		l.Add(i, i)
+	}
+	l.AddWithExp(4, 4, time.Now().Add(-time.Second))
+
+	counts := make(map[int]int)
+	draws := 40000
+	for i := 0; i < draws; i++ {
+		k, ok := l.RandomKey()
+		if !ok {
+			t.Fatalf("should have found a random key")
+		}
+		counts[k]++
+	}
+	if counts[4] != 0 {
+		t.Errorf("expired key should not be returned: %v", counts)
+	}
+	for k := 0; k < 4; k++ {
+		if n := counts[k]; n < draws/4*9/10 || n > draws/4*11/10 {
+			t.Errorf("key %d should be drawn about a quarter of the time: %v", k, counts)
+		}
+	}
+	if k, _, _ := l.GetOldest(); k != 0 {
+		t.Errorf("RandomKey should not have updated recent-ness: %v", k)
+	}
+}
+
+func TestLRU_RandomKeys(t *testing.T) {
+	l, err := NewLRUWithEvictTTL(8, nil, 0, WithRandSeed[int, int](1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(i, i)
+	}
+
+	keys := l.RandomKeys(5)
+	if len(keys) != 5 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	seen := make(map[int]bool)
+	for _, k := range keys {
+		if seen[k] || !l.Contains(k) {
+			t.Errorf("keys should be distinct and contained: %v", keys)
+		}
+		seen[k] = true
+	}
+
+	if keys := l.RandomKeys(100); len(keys) != 8 {
+		t.Errorf("should have returned all keys: %v", keys)
+	}
+	if keys := l.RandomKeys(math.MaxInt); len(keys) != 8 {
+		t.Errorf("should have returned all keys for a huge n: %v", keys)
+	}
+
+	// The same seed yields the same keys
+	other, err := NewLRUWithEvictTTL(8, nil, 0, WithRandSeed[int, int](1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		other.Add(i, i)
+	}
+	l, _ = NewLRUWithEvictTTL(8, nil, 0, WithRandSeed[int, int](1))
+	for i := 0; i < 8; i++ {
+		l.Add(i, i)
+	}
+	a, b := l.RandomKeys(3), other.RandomKeys(3)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Errorf("seeded caches should return the same keys: %v != %v", a, b)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+// GetOrReserve looks up a key like Get. On a miss it reserves the key, unless
+// it is already reserved, to guard against many callers computing the same
+// missing value at once: only the caller which gets reserved is responsible
+// for computing the value, and must then call Fulfill or Abandon. Other
+// callers get neither hit nor reserved until then, and may compute the
+// value independently or retry later.
+func (c *LRU[K, V]) GetOrReserve(key K) (value V, hit, reserved bool) {
+	if value, ok := c.Get(key); ok {
+		return value, true, false
+	}
+	if _, ok := c.reserved[key]; ok {
+		return value, false, false
+	}
+	if c.reserved == nil {
+		c.reserved = make(map[K]struct{})
+	}
+	c.reserved[key] = struct{}{}
+	return value, false, true
+}
+
+// Fulfill adds the value computed for a key reserved by GetOrReserve like
+// Add, and releases the reservation. Returns true if an eviction occurred.
+func (c *LRU[K, V]) Fulfill(key K, value V) (evicted bool) {
+	delete(c.reserved, key)
+	return c.Add(key, value)
+}
+
+// Abandon releases the reservation of a key by GetOrReserve without adding
+// a value, so that the next caller missing the key reserves it.
+func (c *LRU[K, V]) Abandon(key K) {
+	delete(c.reserved, key)
+}
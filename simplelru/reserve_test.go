@@ -0,0 +1,34 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import "testing"
+
+func TestLRU_GetOrReserve(t *testing.T) {
+	l, err := NewLRU[int, int](2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, hit, reserved := l.GetOrReserve(1); hit || !reserved {
+		t.Errorf("first miss should reserve: %v, %v", hit, reserved)
+	}
+	if _, hit, reserved := l.GetOrReserve(1); hit || reserved {
+		t.Errorf("reserved key should not be reserved again: %v, %v", hit, reserved)
+	}
+
+	l.Fulfill(1, 10)
+	if v, hit, reserved := l.GetOrReserve(1); v != 10 || !hit || reserved {
+		t.Errorf("fulfilled key should be a hit: %v, %v, %v", v, hit, reserved)
+	}
+
+	l.GetOrReserve(2)
+	l.Abandon(2)
+	if _, hit, reserved := l.GetOrReserve(2); hit || !reserved {
+		t.Errorf("abandoned key should be reserved again: %v, %v", hit, reserved)
+	}
+	if l.Contains(2) {
+		t.Errorf("abandoned key should not have been added")
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import "time"
+
+// Set is a non-thread safe fixed size set of keys, which drops the least
+// recently added ones when full, e.g. to remember recently seen keys.
+type Set[K comparable] struct {
+	lru *LRU[K, struct{}]
+}
+
+// NewSet constructs a Set of the given size, whose members expire after
+// ttl if it is positive.
+func NewSet[K comparable](size int, ttl time.Duration, opts ...Option[K, struct{}]) (*Set[K], error) {
+	lru, err := NewLRUWithEvictTTL[K, struct{}](size, nil, ttl, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Set[K]{lru: lru}, nil
+}
+
+// Add adds key to the set, or makes it the most recently added one if it is
+// already a member, renewing its expiry. added reports whether key was not
+// a member, and evicted whether another member was dropped to make room.
+func (s *Set[K]) Add(key K) (added, evicted bool) {
+	_, existed, evicted, err := s.lru.AddReplacing(key, struct{}{}, time.Time{})
+	return err == nil && !existed, evicted
+}
+
+// Contains checks if key is a member of the set, without making it the most
+// recently added one.
+func (s *Set[K]) Contains(key K) bool {
+	return s.lru.Contains(key)
+}
+
+// Remove removes key from the set, returning if it was a member.
+func (s *Set[K]) Remove(key K) bool {
+	return s.lru.Remove(key)
+}
+
+// Len returns the number of members of the set, which may include expired
+// ones not removed yet.
+func (s *Set[K]) Len() int {
+	return s.lru.Len()
+}
+
+// Items returns the members of the set, from oldest to newest.
+func (s *Set[K]) Items() []K {
+	return s.lru.Keys()
+}
@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSet(t *testing.T) {
+	s, err := NewSet[string](2, 0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if added, evicted := s.Add("a"); !added || evicted {
+		t.Errorf("a should have been added: %v, %v", added, evicted)
+	}
+	if added, evicted := s.Add("b"); !added || evicted {
+		t.Errorf("b should have been added: %v, %v", added, evicted)
+	}
+	if added, evicted := s.Add("a"); added || evicted {
+		t.Errorf("a should have been a member: %v, %v", added, evicted)
+	}
+	if s.Len() != 2 || !s.Contains("a") || !s.Contains("b") {
+		t.Errorf("bad members: %v", s.Items())
+	}
+
+	// a was added again, so b is the oldest member
+	if added, evicted := s.Add("c"); !added || !evicted {
+		t.Errorf("c should have evicted a member: %v, %v", added, evicted)
+	}
+	if items := s.Items(); !reflect.DeepEqual(items, []string{"a", "c"}) {
+		t.Errorf("bad members: %v", items)
+	}
+
+	if !s.Remove("a") || s.Remove("a") || s.Contains("a") {
+		t.Errorf("a should have been removed once")
+	}
+	if s.Len() != 1 {
+		t.Errorf("bad len: %v", s.Len())
+	}
+
+	if _, err := NewSet[string](0, 0); err == nil {
+		t.Errorf("should fail without a size")
+	}
+}
+
+func TestSet_TTL(t *testing.T) {
+	clock := newFakeClock()
+	s, err := NewSet(4, time.Minute, WithClock[int, struct{}](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	s.Add(1)
+	s.Add(2)
+	clock.Advance(time.Second * 40)
+	// Adding a member again renews its expiry
+	s.Add(1)
+	s.Add(3)
+	clock.Advance(time.Second * 40)
+
+	if !s.Contains(1) || s.Contains(2) || !s.Contains(3) {
+		t.Errorf("only 2 should have expired: %v", s.Items())
+	}
+	if added, _ := s.Add(2); !added {
+		t.Errorf("expired member should be added again")
+	}
+	if items := s.Items(); !reflect.DeepEqual(items, []int{1, 3, 2}) {
+		t.Errorf("bad members: %v", items)
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"errors"
+	"time"
+)
+
+// SmallLRUSize is the largest size for which NewLRUCache returns a
+// slice-backed cache instead of an LRU.
+const SmallLRUSize = 16
+
+// NewLRUCache constructs an LRUCache of the given size. Up to SmallLRUSize
+// the entries are kept in plain slices instead of a linked list and a map,
+// which has better cache locality and does not allocate per entry. Moving an
+// entry to the front shifts the slices, which is cheap at such sizes.
+func NewLRUCache[K comparable, V any](size int, onEvict EvictCallback[K, V], itemTTL time.Duration) (LRUCache[K, V], error) {
+	if size > SmallLRUSize {
+		return NewLRUWithEvictTTL[K, V](size, onEvict, itemTTL)
+	}
+	return newSmallLRU[K, V](size, onEvict, itemTTL)
+}
+
+// smallLRU implements a non-thread safe fixed size LRU cache backed by
+// slices ordered from oldest to newest.
+type smallLRU[K comparable, V any] struct {
+	size     int
+	keys     []K
+	values   []V
+	expiries []time.Time
+	onEvict  EvictCallback[K, V]
+	itemTTL  time.Duration
+}
+
+func newSmallLRU[K comparable, V any](size int, onEvict EvictCallback[K, V], itemTTL time.Duration) (*smallLRU[K, V], error) {
+	if size <= 0 {
+		return nil, errors.New("must provide a positive size")
+	}
+
+	c := &smallLRU[K, V]{
+		size:     size,
+		keys:     make([]K, 0, size+1),
+		values:   make([]V, 0, size+1),
+		expiries: make([]time.Time, 0, size+1),
+		onEvict:  onEvict,
+		itemTTL:  itemTTL,
+	}
+	return c, nil
+}
+
+// Purge is used to completely clear the cache.
+func (c *smallLRU[K, V]) Purge() {
+	if c.onEvict != nil {
+		for i := range c.keys {
+			c.onEvict(c.keys[i], c.values[i])
+		}
+	}
+	clearSlice(c.keys)
+	clearSlice(c.values)
+	c.keys, c.values, c.expiries = c.keys[:0], c.values[:0], c.expiries[:0]
+}
+
+// Add adds a value to the cache.  Returns true if an eviction occurred.
+func (c *smallLRU[K, V]) Add(key K, value V) (evicted bool) {
+	return c.AddWithExp(key, value, time.Time{})
+}
+
+// AddWithExp adds a value to the cache allows for specific time to expire value.
+// If provided time IsZero() the caches own TTL will be used (if available).
+// Returns true if an eviction occurred.
+func (c *smallLRU[K, V]) AddWithExp(key K, value V, expiry time.Time) (evicted bool) {
+	// Check for existing item
+	if i := c.index(key); i >= 0 {
+		i = c.moveToFront(i)
+		if c.onEvict != nil {
+			c.onEvict(key, c.values[i])
+		}
+		c.values[i] = value
+		if !expiry.IsZero() {
+			c.expiries[i] = expiry
+		} else if c.itemTTL > 0 {
+			c.expiries[i] = time.Now().Add(c.itemTTL)
+		}
+		return false
+	}
+
+	evict := len(c.keys) >= c.size
+	if evict {
+		c.removeOldest()
+	}
+
+	// Add new item
+	if expiry.IsZero() && c.itemTTL > 0 {
+		expiry = time.Now().Add(c.itemTTL)
+	}
+	c.keys = append(c.keys, key)
+	c.values = append(c.values, value)
+	c.expiries = append(c.expiries, expiry)
+
+	// Verify size not exceeded
+	if len(c.keys) > c.size {
+		c.removeOldest()
+	}
+	return evict
+}
+
+// Get looks up a key's value from the cache.
+func (c *smallLRU[K, V]) Get(key K) (value V, ok bool) {
+	if i := c.index(key); i >= 0 {
+		if !c.expired(i) {
+			i = c.moveToFront(i)
+			return c.values[i], true
+		}
+		c.removeAt(i)
+	}
+	return
+}
+
+// Contains checks if a key is in the cache, without updating the recent-ness
+// or deleting it for being stale.
+func (c *smallLRU[K, V]) Contains(key K) (ok bool) {
+	if i := c.index(key); i >= 0 {
+		if !c.expired(i) {
+			return true
+		}
+		c.removeAt(i)
+	}
+	return
+}
+
+// Peek returns the key value (or undefined if not found) without updating
+// the "recently used"-ness of the key.
+func (c *smallLRU[K, V]) Peek(key K) (value V, ok bool) {
+	if i := c.index(key); i >= 0 {
+		if !c.expired(i) {
+			return c.values[i], true
+		}
+		c.removeAt(i)
+	}
+	return
+}
+
+// Remove removes the provided key from the cache, returning if the
+// key was contained.
+func (c *smallLRU[K, V]) Remove(key K) (present bool) {
+	if i := c.index(key); i >= 0 {
+		present = !c.expired(i)
+		c.removeAt(i)
+	}
+	return
+}
+
+// RemoveOldest removes the oldest item from the cache.
+func (c *smallLRU[K, V]) RemoveOldest() (key K, value V, ok bool) {
+	if c.dropExpiredOldest() {
+		key, value = c.keys[0], c.values[0]
+		c.removeAt(0)
+		return key, value, true
+	}
+	return
+}
+
+// GetOldest returns the oldest entry
+func (c *smallLRU[K, V]) GetOldest() (key K, value V, ok bool) {
+	if c.dropExpiredOldest() {
+		return c.keys[0], c.values[0], true
+	}
+	return
+}
+
+// Keys returns a slice of the keys in the cache, from oldest to newest.
+func (c *smallLRU[K, V]) Keys() []K {
+	c.RemoveExpired()
+	keys := make([]K, len(c.keys))
+	copy(keys, c.keys)
+	return keys
+}
+
+// Values returns a slice of the values in the cache, from oldest to newest.
+func (c *smallLRU[K, V]) Values() []V {
+	c.RemoveExpired()
+	values := make([]V, len(c.values))
+	copy(values, c.values)
+	return values
+}
+
+// Len returns the physical number of items in the cache.
+// This may include items that are inaccessible due to having expired.
+func (c *smallLRU[K, V]) Len() int {
+	return len(c.keys)
+}
+
+// ItemCount returns the number of accessible items in the cache.
+func (c *smallLRU[K, V]) ItemCount() int {
+	return len(c.Keys())
+}
+
+// Resize changes the cache size.
+func (c *smallLRU[K, V]) Resize(size int) (evicted int) {
+	diff := len(c.keys) - size
+	if diff < 0 {
+		diff = 0
+	}
+	for i := 0; i < diff; i++ {
+		c.removeOldest()
+	}
+	c.size = size
+	return diff
+}
+
+// KeyHasExpired checks if a given key has expired.
+func (c *smallLRU[K, V]) KeyHasExpired(key K) (expired bool) {
+	i := c.index(key)
+	return i >= 0 && c.expired(i)
+}
+
+// ExpiryForKey returns the expiry for a given key.
+// If key is not found or does not expire a new instance of time.Time will be returned.
+func (c *smallLRU[K, V]) ExpiryForKey(key K) (expiry time.Time) {
+	if i := c.index(key); i >= 0 {
+		return c.expiries[i]
+	}
+	return
+}
+
+// RemoveExpired removes all expired entries from the cache.
+func (c *smallLRU[K, V]) RemoveExpired() (evicted int) {
+	for i := 0; i < len(c.keys); {
+		if c.expired(i) {
+			c.removeAt(i)
+			evicted++
+			continue
+		}
+		i++
+	}
+	return
+}
+
+// index returns the position of key, or -1 if it is not in the cache.
+func (c *smallLRU[K, V]) index(key K) int {
+	for i := range c.keys {
+		if c.keys[i] == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// expired checks if the entry at position i has expired.
+func (c *smallLRU[K, V]) expired(i int) bool {
+	expiry := c.expiries[i]
+	return !expiry.IsZero() && expiry.Before(time.Now())
+}
+
+// moveToFront moves the entry at position i to the newest position,
+// which it returns.
+func (c *smallLRU[K, V]) moveToFront(i int) int {
+	last := len(c.keys) - 1
+	if i == last {
+		return i
+	}
+	k, v, e := c.keys[i], c.values[i], c.expiries[i]
+	copy(c.keys[i:], c.keys[i+1:])
+	copy(c.values[i:], c.values[i+1:])
+	copy(c.expiries[i:], c.expiries[i+1:])
+	c.keys[last], c.values[last], c.expiries[last] = k, v, e
+	return last
+}
+
+// removeAt removes the entry at position i from the cache.
+func (c *smallLRU[K, V]) removeAt(i int) {
+	k, v := c.keys[i], c.values[i]
+	last := len(c.keys) - 1
+	copy(c.keys[i:], c.keys[i+1:])
+	copy(c.values[i:], c.values[i+1:])
+	copy(c.expiries[i:], c.expiries[i+1:])
+	clearSlice(c.keys[last:])
+	clearSlice(c.values[last:])
+	c.keys, c.values, c.expiries = c.keys[:last], c.values[:last], c.expiries[:last]
+	if c.onEvict != nil {
+		c.onEvict(k, v)
+	}
+}
+
+// removeOldest removes the oldest item from the cache,
+// preferring expired items if the cache has a TTL.
+func (c *smallLRU[K, V]) removeOldest() {
+	if c.itemTTL > 0 {
+		for i := range c.keys {
+			if c.expired(i) {
+				c.removeAt(i)
+				return
+			}
+		}
+	}
+	if c.dropExpiredOldest() {
+		c.removeAt(0)
+	}
+}
+
+// dropExpiredOldest removes expired entries from the oldest end until a
+// live entry is found, reporting whether there is one.
+func (c *smallLRU[K, V]) dropExpiredOldest() bool {
+	for len(c.keys) > 0 {
+		if !c.expired(0) {
+			return true
+		}
+		c.removeAt(0)
+	}
+	return false
+}
+
+// clearSlice sets all elements of s to their zero value,
+// so that removed keys and values can be garbage collected.
+func clearSlice[T any](s []T) {
+	var zero T
+	for i := range s {
+		s[i] = zero
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func benchmarkLRUCacheSize(b *testing.B, newCache func(int, EvictCallback[int, int]) (LRUCache[int, int], error)) {
+	for _, size := range []int{8, 16, 32} {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			l, err := newCache(size, nil)
+			if err != nil {
+				b.Fatalf("err: %v", err)
+			}
+
+			r := rand.New(rand.NewSource(1))
+			trace := make([]int, 4096)
+			for i := range trace {
+				trace[i] = r.Intn(size * 2)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				k := trace[i%len(trace)]
+				if i%2 == 0 {
+					l.Add(k, k)
+				} else {
+					l.Get(k)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSmallLRU_Slice(b *testing.B) {
+	benchmarkLRUCacheSize(b, func(size int, onEvict EvictCallback[int, int]) (LRUCache[int, int], error) {
+		return newSmallLRU(size, onEvict, 0)
+	})
+}
+
+func BenchmarkSmallLRU_List(b *testing.B) {
+	benchmarkLRUCacheSize(b, func(size int, onEvict EvictCallback[int, int]) (LRUCache[int, int], error) {
+		return NewLRU(size, onEvict)
+	})
+}
+
+func TestNewLRUCache(t *testing.T) {
+	l, err := NewLRUCache[int, int](SmallLRUSize, nil, 0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := l.(*smallLRU[int, int]); !ok {
+		t.Errorf("should be slice-backed: %T", l)
+	}
+
+	l, err = NewLRUCache[int, int](SmallLRUSize+1, nil, 0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := l.(*LRU[int, int]); !ok {
+		t.Errorf("should be list-backed: %T", l)
+	}
+
+	if _, err := NewLRUCache[int, int](0, nil, 0); err == nil {
+		t.Errorf("should have failed for size 0")
+	}
+}
+
+// Test that the slice-backed and list-backed caches evict the same entries
+func TestSmallLRU_SameAsLRU(t *testing.T) {
+	var smallEvicted, listEvicted []int
+	small, err := newSmallLRU(8, func(k, v int) { smallEvicted = append(smallEvicted, k) }, 0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	list, err := NewLRU(8, func(k, v int) { listEvicted = append(listEvicted, k) })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	now := time.Now()
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		k := r.Intn(24)
+		switch r.Intn(7) {
+		case 0, 1:
+			if small.Add(k, k) != list.Add(k, k) {
+				t.Fatalf("Add(%v) should report the same eviction", k)
+			}
+		case 2, 3:
+			v1, ok1 := small.Get(k)
+			v2, ok2 := list.Get(k)
+			if v1 != v2 || ok1 != ok2 {
+				t.Fatalf("Get(%v) mismatch: %v, %v != %v, %v", k, v1, ok1, v2, ok2)
+			}
+		case 4:
+			if small.Remove(k) != list.Remove(k) {
+				t.Fatalf("Remove(%v) mismatch", k)
+			}
+		case 5:
+			k1, _, ok1 := small.RemoveOldest()
+			k2, _, ok2 := list.RemoveOldest()
+			if k1 != k2 || ok1 != ok2 {
+				t.Fatalf("RemoveOldest mismatch: %v, %v != %v, %v", k1, ok1, k2, ok2)
+			}
+		case 6:
+			// no expiry, or one which has passed or is far away
+			var expiry time.Time
+			if d := r.Intn(3); d > 0 {
+				expiry = now.Add(time.Duration(d*2-3) * time.Hour)
+			}
+			if small.AddWithExp(k, k, expiry) != list.AddWithExp(k, k, expiry) {
+				t.Fatalf("AddWithExp(%v) should report the same eviction", k)
+			}
+			if e1, e2 := small.ExpiryForKey(k), list.ExpiryForKey(k); !e1.Equal(e2) {
+				t.Fatalf("ExpiryForKey(%v) mismatch: %v != %v", k, e1, e2)
+			}
+		}
+		if !reflect.DeepEqual(small.Keys(), list.Keys()) {
+			t.Fatalf("keys mismatch: %v != %v", small.Keys(), list.Keys())
+		}
+	}
+
+	if !reflect.DeepEqual(smallEvicted, listEvicted) {
+		t.Errorf("eviction sequences differ:\n%v\n%v", smallEvicted, listEvicted)
+	}
+
+	small.Resize(3)
+	list.Resize(3)
+	if !reflect.DeepEqual(small.Values(), list.Values()) {
+		t.Errorf("values mismatch after resize: %v != %v", small.Values(), list.Values())
+	}
+}
+
+func TestSmallLRU_TTL(t *testing.T) {
+	l, err := newSmallLRU[int, int](4, nil, 0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expiry := time.Now().Add(time.Hour)
+	l.AddWithExp(1, 1, time.Now().Add(-time.Second))
+	l.AddWithExp(2, 2, expiry)
+
+	if !l.KeyHasExpired(1) || l.KeyHasExpired(2) {
+		t.Errorf("only 1 should have expired")
+	}
+	if !l.ExpiryForKey(2).Equal(expiry) {
+		t.Errorf("bad expiry: %v", l.ExpiryForKey(2))
+	}
+	if l.Len() != 2 || l.ItemCount() != 1 || l.Len() != 1 {
+		t.Errorf("expired entry should have been removed by ItemCount")
+	}
+	if _, ok := l.Get(1); ok {
+		t.Errorf("1 should have expired")
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Split partitions the entries of the cache which have not expired into n
+// new caches, putting each entry into the cache shardOf returns for its
+// key, which must be in [0, n). The entries keep their values, expiries and
+// order from oldest to newest. Each new cache has the settings returned by
+// Config, the clock and the eviction callback of c, and the capacity of c
+// divided by n and rounded up, or more if needed to hold all entries put
+// into it.
+// If drain is true the entries are removed from c, without calling the
+// eviction callback, otherwise c is left intact.
+func (c *LRU[K, V]) Split(n int, shardOf func(K) int, drain bool) ([]*LRU[K, V], error) {
+	if n <= 0 {
+		return nil, errors.New("must provide a positive number of shards")
+	}
+
+	parts := make([][]Entry[K, V], n)
+	now := c.now()
+	for ent := c.evictList.back(); ent != nil; ent = ent.prevEntry() {
+		expiry, ok := c.itemExpiries[ent.key]
+		if ok && expiry.Before(now) {
+			continue
+		}
+		i := shardOf(ent.key)
+		if i < 0 || i >= n {
+			return nil, fmt.Errorf("shard %d of key %v is out of range", i, ent.key)
+		}
+		parts[i] = append(parts[i], Entry[K, V]{Key: ent.key, Value: ent.value, Expiry: expiry})
+	}
+
+	cfg := c.Config()
+	size := (c.size + n - 1) / n
+	shards := make([]*LRU[K, V], n)
+	for i, entries := range parts {
+		cfg.Size = size
+		if len(entries) > cfg.Size {
+			cfg.Size = len(entries)
+		}
+		if cfg.Size == 0 {
+			cfg.Size = 1
+		}
+		shard, err := NewFromConfig(cfg, c.onEvict, WithClock[K, V](c.now))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			shard.AddWithExp(e.Key, e.Value, e.Expiry)
+			if e.Expiry.IsZero() && len(shard.itemExpiries) > 0 {
+				// the entry never expires, so drop the TTL of the cache
+				delete(shard.itemExpiries, e.Key)
+			}
+		}
+		shards[i] = shard
+	}
+
+	if drain {
+		c.removeAll()
+	}
+	return shards, nil
+}
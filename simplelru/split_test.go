@@ -0,0 +1,80 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLRU_Split(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(10, nil, time.Hour, WithClock[int, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.AddWithExp(i, i*10, clock.Now().Add(time.Duration(i+1)*time.Minute))
+		clock.Advance(time.Second)
+	}
+	l.Add(8, 80)
+	l.Get(2)
+	// 0 expires, so it is not put into any shard
+	clock.Advance(time.Minute)
+
+	shardOf := func(k int) int { return k % 3 }
+	shards, err := l.Split(3, shardOf, false)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(shards) != 3 {
+		t.Fatalf("bad shards: %v", len(shards))
+	}
+
+	expectedKeys := [][]int{{3, 6}, {1, 4, 7}, {5, 8, 2}}
+	for i, shard := range shards {
+		if shard.Config().Size != 4 {
+			t.Errorf("bad capacity of shard %d: %d", i, shard.Config().Size)
+		}
+		keys := shard.Keys()
+		if !reflect.DeepEqual(keys, expectedKeys[i]) {
+			t.Errorf("bad keys of shard %d: %v", i, keys)
+		}
+		for _, k := range keys {
+			if v, _ := shard.Peek(k); v != k*10 {
+				t.Errorf("bad value of %d: %d", k, v)
+			}
+			if exp := shard.ExpiryForKey(k); !exp.Equal(l.ExpiryForKey(k)) {
+				t.Errorf("bad expiry of %d: %v", k, exp)
+			}
+		}
+	}
+	if l.Len() != 9 {
+		t.Errorf("source should have been left intact: %v", l.Len())
+	}
+
+	// Draining, with all entries in one shard
+	shards, err = l.Split(2, func(int) int { return 1 }, true)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if shards[0].Len() != 0 || shards[1].Len() != 8 || shards[1].Config().Size != 8 {
+		t.Errorf("bad shards: %v/%v, %v/%v", shards[0].Len(), shards[0].Config().Size, shards[1].Len(), shards[1].Config().Size)
+	}
+	if l.Len() != 0 {
+		t.Errorf("source should have been drained: %v", l.Len())
+	}
+
+	if _, err := l.Split(0, shardOf, false); err == nil {
+		t.Errorf("should fail without shards")
+	}
+	l.Add(1, 1)
+	if _, err := l.Split(1, shardOf, true); err == nil {
+		t.Errorf("should fail for a shard out of range")
+	}
+	if l.Len() != 1 {
+		t.Errorf("source should not be drained on failure: %v", l.Len())
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Stats holds counters of the operations on a cache.
+type Stats struct {
+	// Hits and Misses count the lookups with Get.
+	Hits   uint64
+	Misses uint64
+
+	// PeekHits, PeekMisses, ContainsHits and ContainsMisses count the
+	// lookups with Peek and Contains, which are kept apart from those
+	// with Get as they do not affect eviction.
+	PeekHits       uint64
+	PeekMisses     uint64
+	ContainsHits   uint64
+	ContainsMisses uint64
+
+	// Evictions counts the live entries removed to make room.
+	Evictions uint64
+
+	// Expirations counts the expired entries removed.
+	Expirations uint64
+
+	// ExpiredReads counts the lookups with Get which found an
+	// expired entry, whether or not it was removed.
+	ExpiredReads uint64
+}
+
+// HitRate returns the fraction of the lookups with Get which were hits.
+// ok is false if there were none.
+func (s Stats) HitRate() (rate float64, ok bool) {
+	lookups := s.Hits + s.Misses
+	if lookups == 0 {
+		return 0, false
+	}
+	return float64(s.Hits) / float64(lookups), true
+}
+
+// AggregateStats sums the stats of the given caches, such as LRU or the
+// thread-safe Cache of the parent package, for a view across all of them.
+// The HitRate of the result is the combined one of all lookups.
+func AggregateStats(caches ...interface{ Stats() Stats }) (total Stats) {
+	for _, c := range caches {
+		s := c.Stats()
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+		total.PeekHits += s.PeekHits
+		total.PeekMisses += s.PeekMisses
+		total.ContainsHits += s.ContainsHits
+		total.ContainsMisses += s.ContainsMisses
+		total.Evictions += s.Evictions
+		total.Expirations += s.Expirations
+		total.ExpiredReads += s.ExpiredReads
+	}
+	return total
+}
+
+// statsEvent is an operation counted in Stats.
+type statsEvent uint8
+
+const (
+	eventHit statsEvent = iota
+	eventMiss
+	eventEviction
+	eventExpiration
+	eventExpiredRead
+	eventPeekHit
+	eventPeekMiss
+	eventContainsHit
+	eventContainsMiss
+)
+
+// add counts an event.
+func (s *Stats) add(ev statsEvent) {
+	switch ev {
+	case eventHit:
+		s.Hits++
+	case eventMiss:
+		s.Misses++
+	case eventEviction:
+		s.Evictions++
+	case eventExpiration:
+		s.Expirations++
+	case eventExpiredRead:
+		s.ExpiredReads++
+	case eventPeekHit:
+		s.PeekHits++
+	case eventPeekMiss:
+		s.PeekMisses++
+	case eventContainsHit:
+		s.ContainsHits++
+	case eventContainsMiss:
+		s.ContainsMisses++
+	}
+}
+
+// eventRing keeps the times of the most recent events in a fixed size
+// buffer, overwriting the oldest ones.
+type eventRing struct {
+	times  []time.Time
+	events []statsEvent
+	next   int
+	full   bool
+}
+
+func newEventRing(size int) *eventRing {
+	return &eventRing{
+		times:  make([]time.Time, size),
+		events: make([]statsEvent, size),
+	}
+}
+
+// add records an event which occurred at the given time.
+func (r *eventRing) add(at time.Time, ev statsEvent) {
+	r.times[r.next] = at
+	r.events[r.next] = ev
+	r.next++
+	if r.next == len(r.times) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// since counts the recorded events which occurred after start.
+func (r *eventRing) since(start time.Time) (s Stats) {
+	n := r.next
+	if r.full {
+		n = len(r.times)
+	}
+	for i := 0; i < n; i++ {
+		j := (r.next - 1 - i + len(r.times)) % len(r.times)
+		if !r.times[j].After(start) {
+			break
+		}
+		s.add(r.events[j])
+	}
+	return s
+}
+
+// record counts an event in the stats of the cache.
+func (c *LRU[K, V]) record(ev statsEvent) {
+	c.stats.add(ev)
+	if c.events != nil {
+		c.events.add(c.now(), ev)
+	}
+}
+
+// Stats returns the counters of the operations on the cache.
+func (c *LRU[K, V]) Stats() Stats {
+	return c.stats
+}
+
+// PeakLen returns the largest number of entries the cache has held,
+// including expired ones, since it was created.
+func (c *LRU[K, V]) PeakLen() int {
+	return c.peakLen
+}
+
+// WindowedStats returns the counters of the operations on the cache within
+// the trailing window. Only the most recent operations are kept, as many as
+// set by WithStatsWindow, so the counts are too low if more operations than
+// that occurred within the window. Without WithStatsWindow all counts are zero.
+func (c *LRU[K, V]) WindowedStats(window time.Duration) Stats {
+	if c.events == nil {
+		return Stats{}
+	}
+	return c.events.since(c.now().Add(-window))
+}
+
+// WriteMetrics writes the size of the cache, its peak, its stats and the
+// time of the next expiry to w as lines of "name: value", for use on a
+// debug page.
+// The hit rate is "n/a" before the first lookup, the next expiry "none"
+// if no live entry expires.
+func (c *LRU[K, V]) WriteMetrics(w io.Writer) error {
+	live, expired := c.LenBreakdown()
+	s := c.stats
+
+	hitRate := "n/a"
+	if rate, ok := s.HitRate(); ok {
+		hitRate = fmt.Sprintf("%.3f", rate)
+	}
+
+	nextExpiry := "none"
+	var next time.Time
+	now := c.now()
+	for _, expiry := range c.itemExpiries {
+		if !expiry.Before(now) && (next.IsZero() || expiry.Before(next)) {
+			next = expiry
+		}
+	}
+	if !next.IsZero() {
+		nextExpiry = fmt.Sprintf("%s (in %s)", next.Round(0).Format(time.RFC3339), next.Sub(now).Round(time.Millisecond))
+	}
+
+	_, err := fmt.Fprintf(w, "len: %d\nexpired: %d\ncap: %d\npeak: %d\nhits: %d\nmisses: %d\nhit_rate: %s\nevictions: %d\nexpirations: %d\nnext_expiry: %s\n",
+		live, expired, c.size, c.peakLen, s.Hits, s.Misses, hitRate, s.Evictions, s.Expirations, nextExpiry)
+	return err
+}
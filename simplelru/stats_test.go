@@ -0,0 +1,209 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simplelru
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock which only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.now = f.now.Add(d)
+}
+
+func TestLRU_WindowedStats(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(2, nil, 0,
+		WithClock[int, int](clock.Now), WithStatsWindow[int, int](16))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1)
+	l.Get(1)
+	l.Get(2)
+	clock.Advance(time.Minute)
+
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.Get(3)
+	l.AddWithExp(4, 4, clock.Now().Add(time.Second))
+	clock.Advance(time.Second * 2)
+	l.Get(4)
+
+	stats := l.WindowedStats(time.Second * 30)
+	expected := Stats{Hits: 1, Misses: 1, Evictions: 2, Expirations: 1, ExpiredReads: 1}
+	if stats != expected {
+		t.Errorf("bad windowed stats: %+v", stats)
+	}
+	stats = l.WindowedStats(time.Hour)
+	expected = Stats{Hits: 2, Misses: 2, Evictions: 2, Expirations: 1, ExpiredReads: 1}
+	if stats != expected || stats != l.Stats() {
+		t.Errorf("bad windowed stats: %+v", stats)
+	}
+
+	// Nothing happened within the last minute
+	clock.Advance(time.Minute)
+	if stats := l.WindowedStats(time.Second * 30); stats != (Stats{}) {
+		t.Errorf("bad windowed stats: %+v", stats)
+	}
+}
+
+func TestLRU_WindowedStatsOverflow(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(2, nil, 0,
+		WithClock[int, int](clock.Now), WithStatsWindow[int, int](4))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		l.Get(i)
+	}
+	if stats := l.WindowedStats(time.Hour); stats.Misses != 4 {
+		t.Errorf("only the last 4 events should have been kept: %+v", stats)
+	}
+	if stats := l.Stats(); stats.Misses != 10 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+
+	l, err = NewLRU[int, int](2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Get(1)
+	if stats := l.WindowedStats(time.Hour); stats != (Stats{}) {
+		t.Errorf("should have no windowed stats: %+v", stats)
+	}
+}
+
+func TestLRU_WriteMetrics(t *testing.T) {
+	clock := newFakeClock()
+	l, err := NewLRUWithEvictTTL(2, nil, 0, WithClock[int, int](clock.Now))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := l.WriteMetrics(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !strings.Contains(buf.String(), "hit_rate: n/a\n") || !strings.Contains(buf.String(), "next_expiry: none\n") {
+		t.Errorf("bad metrics of empty cache:\n%s", buf.String())
+	}
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.AddWithExp(3, 3, clock.Now().Add(time.Minute))
+	l.Get(3)
+	l.Get(3)
+	l.Get(3)
+	l.Get(1)
+
+	buf.Reset()
+	if err := l.WriteMetrics(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, line := range []string{
+		"len: 2\n",
+		"expired: 0\n",
+		"cap: 2\n",
+		"peak: 2\n",
+		"hits: 3\n",
+		"misses: 1\n",
+		"hit_rate: 0.750\n",
+		"evictions: 1\n",
+		"expirations: 0\n",
+		"next_expiry: 2020-01-01T00:01:00Z (in 1m0s)\n",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("metrics should contain %q:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestLRU_PeakLen(t *testing.T) {
+	l, err := NewLRU[int, int](4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		l.Add(i, i)
+	}
+	l.Remove(0)
+	l.Add(0, 0)
+	if n := l.PeakLen(); n != 3 {
+		t.Errorf("bad peak: %v", n)
+	}
+	for i := 0; i < 8; i++ {
+		l.Add(i, i)
+	}
+	l.Purge()
+	if n := l.PeakLen(); n != 4 {
+		t.Errorf("peak should be kept at the size: %v", n)
+	}
+}
+
+func TestAggregateStats(t *testing.T) {
+	if stats := AggregateStats(); stats != (Stats{}) {
+		t.Errorf("bad stats of no caches: %+v", stats)
+	}
+	if _, ok := AggregateStats().HitRate(); ok {
+		t.Errorf("no lookups should have no hit rate")
+	}
+
+	a, err := NewLRU[int, int](2, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	a.Add(1, 1)
+	a.Get(1)
+	a.Get(1)
+	a.Get(2)
+	a.Add(2, 2)
+	a.Add(3, 3)
+
+	b, err := NewLRU[string, int](4, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	b.AddWithExp("a", 1, time.Now().Add(-time.Second))
+	b.Add("b", 2)
+	b.Get("a")
+	b.Get("b")
+	b.Peek("b")
+	b.Contains("c")
+
+	stats := AggregateStats(a, b)
+	expected := Stats{
+		Hits:           3,
+		Misses:         2,
+		PeekHits:       1,
+		ContainsMisses: 1,
+		Evictions:      1,
+		Expirations:    1,
+		ExpiredReads:   1,
+	}
+	if stats != expected {
+		t.Errorf("bad aggregated stats: %+v", stats)
+	}
+	if rate, ok := stats.HitRate(); !ok || rate != 0.6 {
+		t.Errorf("bad hit rate: %v, %v", rate, ok)
+	}
+}
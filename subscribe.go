@@ -0,0 +1,73 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package lru
+
+import (
+	"sync"
+
+	"github.com/craumix/golang-lru/simplelru"
+)
+
+// Event describes a change to an entry of the cache, as delivered to
+// subscribers.
+type Event[K comparable, V any] struct {
+	Type  simplelru.EventType
+	Key   K
+	Value V
+	// Dropped is the number of events which were not delivered to the
+	// subscriber since the previous one, as its channel was full.
+	Dropped uint64
+}
+
+// subscriber is a channel registered with Subscribe.
+type subscriber[K comparable, V any] struct {
+	ch      chan Event[K, V]
+	dropped uint64
+}
+
+// Subscribe returns a channel receiving every change to the entries of the
+// cache, and a function which stops the delivery and closes the channel.
+// Each subscriber has its own channel with the given buffer size. Events are
+// sent without waiting, so when a subscriber does not keep up they are
+// dropped for it, which is reported in the next event it receives.
+func (c *Cache[K, V]) Subscribe(buffer int) (<-chan Event[K, V], func()) {
+	s := &subscriber[K, V]{ch: make(chan Event[K, V], buffer)}
+	c.acquire()
+	c.subs = append(c.subs, s)
+	c.lru.SetOnEvent(c.publish)
+	c.lock.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			c.acquire()
+			defer c.lock.Unlock()
+			for i, sub := range c.subs {
+				if sub == s {
+					c.subs = append(c.subs[:i], c.subs[i+1:]...)
+					break
+				}
+			}
+			close(s.ch)
+			if len(c.subs) == 0 {
+				c.lru.SetOnEvent(nil)
+			}
+		})
+	}
+	return s.ch, unsubscribe
+}
+
+// publish sends an event to all subscribers. It is called by the underlying
+// cache while the lock is held for writing, as all methods which modify the
+// cache hold it.
+func (c *Cache[K, V]) publish(ev simplelru.Event[K, V]) {
+	for _, s := range c.subs {
+		select {
+		case s.ch <- Event[K, V]{Type: ev.Type, Key: ev.Key, Value: ev.Value, Dropped: s.dropped}:
+			s.dropped = 0
+		default:
+			s.dropped++
+		}
+	}
+}